@@ -1,4 +1,4 @@
-// Generated by ESG at 2021-09-18 13:27:23. github.com/simplefelix/esg
+// Scaffolded by ESG at 2021-09-18 13:27:23 and maintained by hand since. See ErrorType.
 
 package u
 
@@ -9,6 +9,7 @@ type AnyError struct {
 	err        interface{}
 	statusCode int
 	errorCode  interface{}
+	stack      *errorStack
 }
 
 // ErrorCode change it as you prefer.
@@ -30,7 +31,7 @@ func (e AnyError) Extra() interface{} {
 }
 
 // SetExtra sets _extra_ with a value by user. Usage of _extra_ is determined by user.
-func (e AnyError) SetExtra(extra interface{}) {
+func (e *AnyError) SetExtra(extra interface{}) {
 	e._extra_ = extra
 }
 
@@ -39,9 +40,20 @@ func (e AnyError) Error() string {
 	return fmt.Sprintf(`%v`, e.err)
 }
 
+// Unwrap returns the cause if it is an error.
+func (e AnyError) Unwrap() error {
+	return causeOf(e.err)
+}
+
+// StackTrace returns the call stack where the error is constructed.
+func (e AnyError) StackTrace() string {
+	return e.stack.String()
+}
+
 // ErrAnyError is convenient constructor.
 func ErrAnyError(err interface{}) AnyError {
 	return AnyError{
+		stack:      callers(),
 		errorCode:  "AnyError",
 		statusCode: 500,
 		err:        err,
@@ -50,6 +62,7 @@ func ErrAnyError(err interface{}) AnyError {
 
 func NewAnyError(errorCode interface{}, err interface{}, statusCode int) AnyError {
 	return AnyError{
+		stack:      callers(),
 		errorCode:  errorCode,
 		statusCode: statusCode,
 		err:        err,
@@ -1,4 +1,4 @@
-// Generated by ESG at 2021-10-13 16:33:12. github.com/simplefelix/esg
+// Scaffolded by ESG at 2021-10-13 16:33:12 and maintained by hand since. See ErrorType.
 
 package u
 
@@ -7,6 +7,7 @@ import "fmt"
 type CantStartConsumer struct {
 	_extra_ interface{}
 	err     interface{}
+	stack   *errorStack
 }
 
 // ErrorCode change it as you prefer.
@@ -37,9 +38,20 @@ func (e CantStartConsumer) Error() string {
 	return fmt.Sprintf(`%v`, e.err)
 }
 
-// ErrCantStartConsumergo install github.com/SimpleFelix/esg/cmd/esg@latest is convenient constructor.
+// Unwrap returns the cause if it is an error.
+func (e CantStartConsumer) Unwrap() error {
+	return causeOf(e.err)
+}
+
+// StackTrace returns the call stack where the error is constructed.
+func (e CantStartConsumer) StackTrace() string {
+	return e.stack.String()
+}
+
+// ErrCantStartConsumer is convenient constructor.
 func ErrCantStartConsumer(err interface{}) CantStartConsumer {
 	return CantStartConsumer{
-		err: err,
+		stack: callers(),
+		err:   err,
 	}
 }
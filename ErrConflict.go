@@ -1,4 +1,4 @@
-// Generated by ESG at 2022-09-06 16:40:36. github.com/simplefelix/esg
+// Scaffolded by ESG at 2022-09-06 16:40:36 and maintained by hand since. See ErrorType.
 
 package u
 
@@ -7,6 +7,7 @@ import "fmt"
 type Conflict struct {
 	_extra_ interface{}
 	err     interface{}
+	stack   *errorStack
 }
 
 // ErrorCode change it as you prefer.
@@ -37,9 +38,20 @@ func (e Conflict) Error() string {
 	return fmt.Sprintf(`%v`, e.err)
 }
 
+// Unwrap returns the cause if it is an error.
+func (e Conflict) Unwrap() error {
+	return causeOf(e.err)
+}
+
+// StackTrace returns the call stack where the error is constructed.
+func (e Conflict) StackTrace() string {
+	return e.stack.String()
+}
+
 // ErrConflict is convenient constructor.
 func ErrConflict(err interface{}) Conflict {
 	return Conflict{
-		err: err,
+		stack: callers(),
+		err:   err,
 	}
 }
@@ -1,4 +1,4 @@
-// Generated by ESG at 2021-11-05 17:13:20. github.com/simplefelix/esg
+// Scaffolded by ESG at 2021-11-05 17:13:20 and maintained by hand since. See ErrorType.
 
 package u
 
@@ -7,6 +7,7 @@ import "fmt"
 type ConsumerError struct {
 	_extra_ interface{}
 	err     interface{}
+	stack   *errorStack
 }
 
 // ErrorCode change it as you prefer.
@@ -28,7 +29,7 @@ func (e ConsumerError) Extra() interface{} {
 }
 
 // SetExtra sets _extra_ with a value by user. Usage of _extra_ is determined by user.
-func (e ConsumerError) SetExtra(extra interface{}) {
+func (e *ConsumerError) SetExtra(extra interface{}) {
 	e._extra_ = extra
 }
 
@@ -37,9 +38,20 @@ func (e ConsumerError) Error() string {
 	return fmt.Sprintf(`%v`, e.err)
 }
 
+// Unwrap returns the cause if it is an error.
+func (e ConsumerError) Unwrap() error {
+	return causeOf(e.err)
+}
+
+// StackTrace returns the call stack where the error is constructed.
+func (e ConsumerError) StackTrace() string {
+	return e.stack.String()
+}
+
 // ErrConsumerError is convenient constructor.
 func ErrConsumerError(err interface{}) ConsumerError {
 	return ConsumerError{
-		err: err,
+		stack: callers(),
+		err:   err,
 	}
 }
@@ -0,0 +1,57 @@
+// Maintained by hand. See ErrorType.
+
+package u
+
+import "fmt"
+
+type ConsumerNotFound struct {
+	_extra_ interface{}
+	err     interface{}
+	stack   *errorStack
+}
+
+// ErrorCode change it as you prefer.
+func (e ConsumerNotFound) ErrorCode() interface{} {
+	return "ConsumerNotFound"
+}
+
+// StatusCode refers to http response status code.
+// Developer may want to set response status code based on error.
+// For example, if the error is caused by bad request, then change the return value to 400.
+// Ignore this function if no need for your project.
+func (e ConsumerNotFound) StatusCode() int {
+	return 404
+}
+
+// Extra returns _extra_ which can be set by user. Usage of _extra_ is determined by user.
+func (e ConsumerNotFound) Extra() interface{} {
+	return e._extra_
+}
+
+// SetExtra sets _extra_ with a value by user. Usage of _extra_ is determined by user.
+func (e *ConsumerNotFound) SetExtra(extra interface{}) {
+	e._extra_ = extra
+}
+
+// Error implementation to error interface.
+func (e ConsumerNotFound) Error() string {
+	return fmt.Sprintf(`%v`, e.err)
+}
+
+// Unwrap returns the cause if it is an error.
+func (e ConsumerNotFound) Unwrap() error {
+	return causeOf(e.err)
+}
+
+// StackTrace returns the call stack where the error is constructed.
+func (e ConsumerNotFound) StackTrace() string {
+	return e.stack.String()
+}
+
+// ErrConsumerNotFound is convenient constructor.
+func ErrConsumerNotFound(err interface{}) ConsumerNotFound {
+	return ConsumerNotFound{
+		stack: callers(),
+		err:   err,
+	}
+}
@@ -1,5 +1,5 @@
 // Package errors
-// Generated by ESG. https://github.com/SimpleFelix/esg
+// Scaffolded by ESG and maintained by hand since. See ErrorType.
 package u
 
 import "fmt"
@@ -7,6 +7,7 @@ import "fmt"
 type DBExecutionError struct {
 	_extra_ interface{}
 	Err     interface{}
+	stack   *errorStack
 }
 
 func (e DBExecutionError) ErrorCode() interface{} {
@@ -31,8 +32,19 @@ func (e DBExecutionError) Error() string {
 	return fmt.Sprintf("%v", e.Err)
 }
 
+// Unwrap returns the cause if it is an error.
+func (e DBExecutionError) Unwrap() error {
+	return causeOf(e.Err)
+}
+
+// StackTrace returns the call stack where the error is constructed.
+func (e DBExecutionError) StackTrace() string {
+	return e.stack.String()
+}
+
 func ErrDBExecutionError(err interface{}) DBExecutionError {
 	return DBExecutionError{
-		Err: err,
+		stack: callers(),
+		Err:   err,
 	}
 }
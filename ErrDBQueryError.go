@@ -1,5 +1,5 @@
 // Package errors
-// Generated by ESG. https://github.com/SimpleFelix/esg
+// Scaffolded by ESG and maintained by hand since. See ErrorType.
 package u
 
 import "fmt"
@@ -7,6 +7,7 @@ import "fmt"
 type DBQueryError struct {
 	_extra_ interface{}
 	err     interface{}
+	stack   *errorStack
 }
 
 func (e DBQueryError) ErrorCode() interface{} {
@@ -31,8 +32,19 @@ func (e DBQueryError) Error() string {
 	return fmt.Sprintf("%v", e.err)
 }
 
+// Unwrap returns the cause if it is an error.
+func (e DBQueryError) Unwrap() error {
+	return causeOf(e.err)
+}
+
+// StackTrace returns the call stack where the error is constructed.
+func (e DBQueryError) StackTrace() string {
+	return e.stack.String()
+}
+
 func ErrDBQueryError(err interface{}) DBQueryError {
 	return DBQueryError{
-		err: err,
+		stack: callers(),
+		err:   err,
 	}
 }
@@ -1,4 +1,4 @@
-// Generated by ESG at 2021-09-29 16:05:18. github.com/simplefelix/esg
+// Scaffolded by ESG at 2021-09-29 16:05:18 and maintained by hand since. See ErrorType.
 
 package u
 
@@ -7,6 +7,7 @@ import "fmt"
 type FailedToMarshalJSON struct {
 	_extra_ interface{}
 	err     interface{}
+	stack   *errorStack
 }
 
 // ErrorCode change it as you prefer.
@@ -37,9 +38,20 @@ func (e FailedToMarshalJSON) Error() string {
 	return fmt.Sprintf(`Failed to marshall JSON to bytes. %v`, e.err)
 }
 
+// Unwrap returns the cause if it is an error.
+func (e FailedToMarshalJSON) Unwrap() error {
+	return causeOf(e.err)
+}
+
+// StackTrace returns the call stack where the error is constructed.
+func (e FailedToMarshalJSON) StackTrace() string {
+	return e.stack.String()
+}
+
 // ErrFailedToMarshalJSON is convenient constructor.
 func ErrFailedToMarshalJSON(err interface{}) FailedToMarshalJSON {
 	return FailedToMarshalJSON{
-		err: err,
+		stack: callers(),
+		err:   err,
 	}
 }
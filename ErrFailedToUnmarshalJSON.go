@@ -1,4 +1,4 @@
-// Generated by ESG at 2021-09-14 14:31:56. github.com/simplefelix/esg
+// Scaffolded by ESG at 2021-09-14 14:31:56 and maintained by hand since. See ErrorType.
 
 package u
 
@@ -7,6 +7,7 @@ import "fmt"
 type FailedToUnmarshalJSON struct {
 	_extra_ interface{}
 	err     interface{}
+	stack   *errorStack
 }
 
 // ErrorCode change it as you prefer.
@@ -28,7 +29,7 @@ func (e FailedToUnmarshalJSON) Extra() interface{} {
 }
 
 // SetExtra sets _extra_ with a value by user. Usage of _extra_ is determined by user.
-func (e FailedToUnmarshalJSON) SetExtra(extra interface{}) {
+func (e *FailedToUnmarshalJSON) SetExtra(extra interface{}) {
 	e._extra_ = extra
 }
 
@@ -37,9 +38,20 @@ func (e FailedToUnmarshalJSON) Error() string {
 	return fmt.Sprintf(`%v`, e.err)
 }
 
+// Unwrap returns the cause if it is an error.
+func (e FailedToUnmarshalJSON) Unwrap() error {
+	return causeOf(e.err)
+}
+
+// StackTrace returns the call stack where the error is constructed.
+func (e FailedToUnmarshalJSON) StackTrace() string {
+	return e.stack.String()
+}
+
 // ErrFailedToUnmarshalJSON convenient constructor
 func ErrFailedToUnmarshalJSON(err interface{}) FailedToUnmarshalJSON {
 	return FailedToUnmarshalJSON{
-		err: err,
+		stack: callers(),
+		err:   err,
 	}
 }
@@ -1,4 +1,4 @@
-// Generated by ESG at 2021-09-20 11:54:59. github.com/simplefelix/esg
+// Scaffolded by ESG at 2021-09-20 11:54:59 and maintained by hand since. See ErrorType.
 
 package u
 
@@ -8,6 +8,7 @@ type GRPCDialErr struct {
 	_extra_ interface{}
 	host    interface{}
 	err     interface{}
+	stack   *errorStack
 }
 
 // ErrorCode change it as you prefer.
@@ -29,7 +30,7 @@ func (e GRPCDialErr) Extra() interface{} {
 }
 
 // SetExtra sets _extra_ with a value by user. Usage of _extra_ is determined by user.
-func (e GRPCDialErr) SetExtra(extra interface{}) {
+func (e *GRPCDialErr) SetExtra(extra interface{}) {
 	e._extra_ = extra
 }
 
@@ -38,10 +39,21 @@ func (e GRPCDialErr) Error() string {
 	return fmt.Sprintf(`Can't dial to grpc server %v. error=%v`, e.host, e.err)
 }
 
+// Unwrap returns the cause if it is an error.
+func (e GRPCDialErr) Unwrap() error {
+	return causeOf(e.err)
+}
+
+// StackTrace returns the call stack where the error is constructed.
+func (e GRPCDialErr) StackTrace() string {
+	return e.stack.String()
+}
+
 // ErrGRPCDialErr is convenient constructor.
 func ErrGRPCDialErr(host, err interface{}) GRPCDialErr {
 	return GRPCDialErr{
-		host: host,
-		err:  err,
+		stack: callers(),
+		host:  host,
+		err:   err,
 	}
 }
@@ -0,0 +1,93 @@
+// Maintained by hand. See ErrorType.
+
+package u
+
+import (
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCRemoteError is an error responded by a gRPC server.
+// ErrorCode and StatusCode are the ones of the ErrorType returned by the server, if it uses GRPCErrorUnaryServerInterceptor.
+// Otherwise, ErrorCode is the gRPC code name and StatusCode is mapped from the gRPC code.
+type GRPCRemoteError struct {
+	_extra_    interface{}
+	status     *status.Status
+	errorCode  interface{}
+	statusCode int
+	tid        string
+	stack      *errorStack
+}
+
+// ErrorCode is ErrorCode of the remote error.
+func (e GRPCRemoteError) ErrorCode() interface{} {
+	return e.errorCode
+}
+
+// StatusCode is StatusCode of the remote error.
+func (e GRPCRemoteError) StatusCode() int {
+	return e.statusCode
+}
+
+// Extra returns _extra_ which can be set by user. Usage of _extra_ is determined by user.
+func (e GRPCRemoteError) Extra() interface{} {
+	return e._extra_
+}
+
+// SetExtra sets _extra_ with a value by user. Usage of _extra_ is determined by user.
+func (e *GRPCRemoteError) SetExtra(extra interface{}) {
+	e._extra_ = extra
+}
+
+// Error implementation to error interface.
+func (e GRPCRemoteError) Error() string {
+	return e.status.Message()
+}
+
+// GRPCStatus keeps status.FromError and status.Code working.
+func (e GRPCRemoteError) GRPCStatus() *status.Status {
+	return e.status
+}
+
+// RemoteTraceID is the trace ID of the server. Empty if unknown.
+func (e GRPCRemoteError) RemoteTraceID() string {
+	return e.tid
+}
+
+// Unwrap returns the status error.
+func (e GRPCRemoteError) Unwrap() error {
+	return e.status.Err()
+}
+
+// StackTrace returns the call stack where the error is constructed.
+func (e GRPCRemoteError) StackTrace() string {
+	return e.stack.String()
+}
+
+// ErrGRPCRemoteError rebuilds the remote ErrorType from ErrorInfo in details of s.
+func ErrGRPCRemoteError(s *status.Status) GRPCRemoteError {
+	e := GRPCRemoteError{
+		status:     s,
+		errorCode:  s.Code().String(),
+		statusCode: httpStatusFromGRPCCode(s.Code()),
+		stack:      callers(),
+	}
+	for _, detail := range s.Details() {
+		info, ok := detail.(*errdetails.ErrorInfo)
+		if !ok || info.Domain != GRPCErrorDomain {
+			continue
+		}
+		e.errorCode = info.Reason
+		e.tid = info.Metadata[grpcErrorMetaTID]
+		if statusCode, err := strconv.Atoi(info.Metadata[grpcErrorMetaHTTPStatus]); err == nil {
+			e.statusCode = statusCode
+		}
+		if info.Metadata[grpcErrorMetaRetryable] == "true" {
+			ErrModRetryable(&e)
+		}
+		break
+	}
+	return e
+}
@@ -1,4 +1,4 @@
-// Generated by ESG at 2021-10-25 16:37:26. github.com/simplefelix/esg
+// Scaffolded by ESG at 2021-10-25 16:37:26 and maintained by hand since. See ErrorType.
 
 package u
 
@@ -7,6 +7,7 @@ import "fmt"
 type internalError struct {
 	_extra_ interface{}
 	err     interface{}
+	stack   *errorStack
 }
 
 // ErrorCode change it as you prefer.
@@ -28,7 +29,7 @@ func (e internalError) Extra() interface{} {
 }
 
 // SetExtra sets _extra_ with a value by user. Usage of _extra_ is determined by user.
-func (e internalError) SetExtra(extra interface{}) {
+func (e *internalError) SetExtra(extra interface{}) {
 	e._extra_ = extra
 }
 
@@ -37,9 +38,20 @@ func (e internalError) Error() string {
 	return fmt.Sprintf(`%v`, e.err)
 }
 
+// Unwrap returns the cause if it is an error.
+func (e internalError) Unwrap() error {
+	return causeOf(e.err)
+}
+
+// StackTrace returns the call stack where the error is constructed.
+func (e internalError) StackTrace() string {
+	return e.stack.String()
+}
+
 // ErrInternalError is convenient constructor.
 func ErrInternalError(err interface{}) internalError {
 	return internalError{
-		err: err,
+		stack: callers(),
+		err:   err,
 	}
 }
@@ -1,4 +1,4 @@
-// Generated by ESG at 2022-09-04 21:18:50. github.com/simplefelix/esg
+// Scaffolded by ESG at 2022-09-04 21:18:50 and maintained by hand since. See ErrorType.
 
 package u
 
@@ -6,7 +6,8 @@ import "fmt"
 
 type InvalidJWT struct {
 	_extra_ interface{}
-	err interface{}
+	err     interface{}
+	stack   *errorStack
 }
 
 // ErrorCode change it as you prefer.
@@ -37,9 +38,20 @@ func (e InvalidJWT) Error() string {
 	return fmt.Sprintf(`%v`, e.err)
 }
 
+// Unwrap returns the cause if it is an error.
+func (e InvalidJWT) Unwrap() error {
+	return causeOf(e.err)
+}
+
+// StackTrace returns the call stack where the error is constructed.
+func (e InvalidJWT) StackTrace() string {
+	return e.stack.String()
+}
+
 // ErrInvalidJWT is convenient constructor.
 func ErrInvalidJWT(err interface{}) InvalidJWT {
 	return InvalidJWT{
-		err: err,
+		stack: callers(),
+		err:   err,
 	}
 }
@@ -1,4 +1,4 @@
-// Generated by ESG at 2021-10-15 18:05:55. github.com/simplefelix/esg
+// Scaffolded by ESG at 2021-10-15 18:05:55 and maintained by hand since. See ErrorType.
 
 package u
 
@@ -7,6 +7,7 @@ import "fmt"
 type MongoQueryErr struct {
 	_extra_ interface{}
 	err     interface{}
+	stack   *errorStack
 }
 
 // ErrorCode change it as you prefer.
@@ -37,9 +38,20 @@ func (e MongoQueryErr) Error() string {
 	return fmt.Sprintf(`%v`, e.err)
 }
 
+// Unwrap returns the cause if it is an error.
+func (e MongoQueryErr) Unwrap() error {
+	return causeOf(e.err)
+}
+
+// StackTrace returns the call stack where the error is constructed.
+func (e MongoQueryErr) StackTrace() string {
+	return e.stack.String()
+}
+
 // ErrMongoQueryErr is convenient constructor.
 func ErrMongoQueryErr(err interface{}) MongoQueryErr {
 	return MongoQueryErr{
-		err: err,
+		stack: callers(),
+		err:   err,
 	}
 }
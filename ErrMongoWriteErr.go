@@ -1,4 +1,4 @@
-// Generated by ESG at 2021-10-15 18:06:02. github.com/simplefelix/esg
+// Scaffolded by ESG at 2021-10-15 18:06:02 and maintained by hand since. See ErrorType.
 
 package u
 
@@ -7,6 +7,7 @@ import "fmt"
 type MongoWriteErr struct {
 	_extra_ interface{}
 	err     interface{}
+	stack   *errorStack
 }
 
 // ErrorCode change it as you prefer.
@@ -37,9 +38,20 @@ func (e MongoWriteErr) Error() string {
 	return fmt.Sprintf(`%v`, e.err)
 }
 
+// Unwrap returns the cause if it is an error.
+func (e MongoWriteErr) Unwrap() error {
+	return causeOf(e.err)
+}
+
+// StackTrace returns the call stack where the error is constructed.
+func (e MongoWriteErr) StackTrace() string {
+	return e.stack.String()
+}
+
 // ErrMongoWriteErr is convenient constructor.
 func ErrMongoWriteErr(err interface{}) MongoWriteErr {
 	return MongoWriteErr{
-		err: err,
+		stack: callers(),
+		err:   err,
 	}
 }
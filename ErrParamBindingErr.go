@@ -1,5 +1,5 @@
 // Package errors
-// Generated by ESG at 2021-09-09 11:52:38. github.com/simplefelix/esg
+// Scaffolded by ESG at 2021-09-09 11:52:38 and maintained by hand since. See ErrorType.
 package u
 
 import "fmt"
@@ -7,6 +7,7 @@ import "fmt"
 type ParamBindingErr struct {
 	_extra_ interface{}
 	err     interface{}
+	stack   *errorStack
 }
 
 func (e ParamBindingErr) ErrorCode() interface{} {
@@ -23,7 +24,7 @@ func (e ParamBindingErr) Extra() interface{} {
 }
 
 // SetExtra sets _extra_ with a value by user. Usage of _extra_ is determined by user.
-func (e ParamBindingErr) SetExtra(extra interface{}) {
+func (e *ParamBindingErr) SetExtra(extra interface{}) {
 	e._extra_ = extra
 }
 
@@ -31,8 +32,19 @@ func (e ParamBindingErr) Error() string {
 	return fmt.Sprintf("%v", e.err)
 }
 
+// Unwrap returns the cause if it is an error.
+func (e ParamBindingErr) Unwrap() error {
+	return causeOf(e.err)
+}
+
+// StackTrace returns the call stack where the error is constructed.
+func (e ParamBindingErr) StackTrace() string {
+	return e.stack.String()
+}
+
 func ErrParamBindingErr(err interface{}) ParamBindingErr {
 	return ParamBindingErr{
-		err: err,
+		stack: callers(),
+		err:   err,
 	}
 }
@@ -1,4 +1,4 @@
-// Generated by ESG at 2021-09-29 09:54:09. github.com/simplefelix/esg
+// Scaffolded by ESG at 2021-09-29 09:54:09 and maintained by hand since. See ErrorType.
 
 package u
 
@@ -6,6 +6,7 @@ import "fmt"
 
 type ShortUUIDLenConstraint struct {
 	_extra_ interface{}
+	stack   *errorStack
 }
 
 // ErrorCode change it as you prefer.
@@ -27,7 +28,7 @@ func (e ShortUUIDLenConstraint) Extra() interface{} {
 }
 
 // SetExtra sets _extra_ with a value by user. Usage of _extra_ is determined by user.
-func (e ShortUUIDLenConstraint) SetExtra(extra interface{}) {
+func (e *ShortUUIDLenConstraint) SetExtra(extra interface{}) {
 	e._extra_ = extra
 }
 
@@ -36,7 +37,19 @@ func (e ShortUUIDLenConstraint) Error() string {
 	return fmt.Sprintf(`length must be in [1, 32]`)
 }
 
+// Unwrap returns the cause if it is an error.
+func (e ShortUUIDLenConstraint) Unwrap() error {
+	return nil
+}
+
+// StackTrace returns the call stack where the error is constructed.
+func (e ShortUUIDLenConstraint) StackTrace() string {
+	return e.stack.String()
+}
+
 // ErrShortUUIDLenConstraint is convenient constructor.
 func ErrShortUUIDLenConstraint() ShortUUIDLenConstraint {
-	return ShortUUIDLenConstraint{}
+	return ShortUUIDLenConstraint{
+		stack: callers(),
+	}
 }
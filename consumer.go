@@ -1,10 +1,14 @@
 package u
 
 import (
+	"context"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/Shopify/sarama"
+	"go.uber.org/zap"
 )
 
 type ConsumerState int
@@ -14,11 +18,18 @@ const (
 	ConsumerStateConsuming
 	ConsumerStateCancelling
 	ConsumerStateRequireRestart
+	// ConsumerStateCancelled is the state of a consumer stopped by Cancel(), e.g. by CancelConsumerHandler.
+	// It can be started again like a ready one.
+	ConsumerStateCancelled
 )
 
 var allConsumers = make(map[string]*CommonConsumer)
 var allConsumersMutex sync.RWMutex
 
+func consumerLog() *zap.SugaredLogger {
+	return SubsystemLogger(LogSubsystemConsumer).Sugar()
+}
+
 func ConsumerForTopic(topic string) *CommonConsumer {
 	allConsumersMutex.RLock()
 	defer allConsumersMutex.RUnlock()
@@ -31,27 +42,62 @@ func saveConsumer(o *CommonConsumer) {
 	allConsumers[o.topic] = o
 }
 
-func deleteConsumer(o *CommonConsumer) {
-	allConsumersMutex.Lock()
-	defer allConsumersMutex.Unlock()
-	delete(allConsumers, o.topic)
-}
-
 type Consumer interface {
 	LastOffset() int64
 	Handle(message *sarama.ConsumerMessage)
 }
 
+// PartitionConsumer is a partition-aware Consumer.
+// If a Consumer implements PartitionConsumer, LastOffsetOfPartition is used instead of LastOffset when CommonConsumer starts consuming.
+type PartitionConsumer interface {
+	Consumer
+	// LastOffsetOfPartition returns the offset of the last handled message of the partition.
+	LastOffsetOfPartition(partition int32) int64
+}
+
+// CTXConsumer is a Consumer which handles each message with a CTX.
+// If a Consumer implements CTXConsumer, HandleWithCTX is called instead of Handle.
+// Trace ID of the CTX is read from header "tid" of the message. See KafkaSyncProducer and KafkaAsyncProducer.
+type CTXConsumer interface {
+	HandleWithCTX(ctx *CTX, message *sarama.ConsumerMessage)
+}
+
 type CommonConsumer struct {
-	canc        chan struct{}
+	canc chan struct{}
+	// cancelMutex guards canc, stopped and state.
 	cancelMutex sync.Mutex
-	state       ConsumerState
-	topic       string
-	sc          sarama.Consumer
+	// stopped is closed when all consuming goroutines stopped. See CancelAndWait.
+	stopped chan struct{}
+	state   ConsumerState
+	topic   string
+	sc      sarama.Consumer
+	// cg is set if r is created by NewGroupConsumer.
+	cg sarama.ConsumerGroup
+
+	// offsets of the last handled message of each partition.
+	offsets    map[int32]int64
+	watermarks map[int32]highWaterMarker
+	// stoppedPartitions are partitions stopped at a message which is neither handled nor sent to dead-letter topic.
+	stoppedPartitions map[int32]bool
+	offsetsMutex      sync.RWMutex
+
+	// See Snapshot.
+	handled     int64
+	lastErr     string
+	lastErrAt   time.Time
+	lastErrLock sync.Mutex
+
+	// See SetRetryPolicy.
+	retryPolicy RetryPolicy
 	*CTX
 	Consumer
 }
 
+// NewConsumer creates a CommonConsumer which consumes every partition of the topic.
+// If Handle panics on a message, and RetryPolicy neither handles it nor sends it to a dead-letter topic,
+// consuming of its partition stops at the message without committing it, while other partitions go on.
+// The partition is reported as Stopped by Snapshot, with the panic in LastError,
+// until Restart() or RestartConsumerHandler consumes it again from the message.
 func NewConsumer(topic string, sc sarama.Consumer) CommonConsumer {
 	return CommonConsumer{
 		topic: topic,
@@ -61,6 +107,8 @@ func NewConsumer(topic string, sc sarama.Consumer) CommonConsumer {
 }
 
 func (r *CommonConsumer) State() ConsumerState {
+	r.cancelMutex.Lock()
+	defer r.cancelMutex.Unlock()
 	return r.state
 }
 
@@ -82,6 +130,84 @@ func (r *CommonConsumer) Topic() string {
 	return r.topic
 }
 
+// Partitions returns the partitions being consumed, sorted by partition ID.
+func (r *CommonConsumer) Partitions() []int32 {
+	r.offsetsMutex.RLock()
+	defer r.offsetsMutex.RUnlock()
+	partitions := make([]int32, 0, len(r.offsets))
+	for p := range r.offsets {
+		partitions = append(partitions, p)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+	return partitions
+}
+
+// LastOffsetOfPartition returns the offset of the last handled message of the partition.
+// Returns -1 if no message of the partition has been handled by r.
+func (r *CommonConsumer) LastOffsetOfPartition(partition int32) int64 {
+	r.offsetsMutex.RLock()
+	defer r.offsetsMutex.RUnlock()
+	offset, ok := r.offsets[partition]
+	if !ok {
+		return -1
+	}
+	return offset
+}
+
+// highWaterMarker is implemented by both sarama.PartitionConsumer and sarama.ConsumerGroupClaim.
+type highWaterMarker interface {
+	HighWaterMarkOffset() int64
+}
+
+func (r *CommonConsumer) setWatermark(partition int32, hwm highWaterMarker) {
+	r.offsetsMutex.Lock()
+	defer r.offsetsMutex.Unlock()
+	if r.watermarks == nil {
+		r.watermarks = map[int32]highWaterMarker{}
+	}
+	r.watermarks[partition] = hwm
+}
+
+// stopPartition marks the partition as stopped. See NewConsumer.
+func (r *CommonConsumer) stopPartition(partition int32) {
+	r.offsetsMutex.Lock()
+	defer r.offsetsMutex.Unlock()
+	if r.stoppedPartitions == nil {
+		r.stoppedPartitions = map[int32]bool{}
+	}
+	r.stoppedPartitions[partition] = true
+}
+
+func (r *CommonConsumer) setLastOffset(partition int32, offset int64) {
+	r.offsetsMutex.Lock()
+	defer r.offsetsMutex.Unlock()
+	if r.offsets == nil {
+		r.offsets = map[int32]int64{}
+	}
+	r.offsets[partition] = offset
+}
+
+// startOffset returns the offset from which the partition should be consumed.
+// Discussion
+// A partition-aware Consumer decides by itself.
+// Otherwise, offsets tracked by r take precedence, which is the case of Restart().
+// Then Consumer.LastOffset() is for partition 0 only, as it used to be; other partitions start from the newest offset.
+func (r *CommonConsumer) startOffset(partition int32) int64 {
+	if pc, ok := r.Consumer.(PartitionConsumer); ok {
+		return pc.LastOffsetOfPartition(partition) + 1
+	}
+	r.offsetsMutex.RLock()
+	offset, ok := r.offsets[partition]
+	r.offsetsMutex.RUnlock()
+	if ok && offset >= 0 {
+		return offset + 1
+	}
+	if partition == 0 {
+		return r.LastOffset() + 1
+	}
+	return sarama.OffsetNewest
+}
+
 func (r *CommonConsumer) Restart() {
 	r.changeState(ConsumerStateRequireRestart)
 }
@@ -90,61 +216,185 @@ func (r *CommonConsumer) Cancel() {
 	r.changeState(ConsumerStateCancelling)
 }
 
+// CancelAndWait cancels r and waits until messages being handled are done or ctx is done.
+func (r *CommonConsumer) CancelAndWait(ctx context.Context) error {
+	r.cancelMutex.Lock()
+	stopped := r.stopped
+	r.cancelMutex.Unlock()
+
+	r.Cancel()
+	if stopped == nil {
+		return nil
+	}
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (r *CommonConsumer) MustStartConsuming() {
 	if err := r.StartConsuming(); err != nil {
 		panic(ErrConsumerError(err))
 	}
 }
 
+// StartOrRestart starts r if it is ready or has been cancelled, or restarts it if it is consuming.
+func (r *CommonConsumer) StartOrRestart() error {
+	r.cancelMutex.Lock()
+	state := r.state
+	if state == ConsumerStateCancelling {
+		// Consuming goroutines are stopping. didStopConsuming starts r again.
+		r.state = ConsumerStateRequireRestart
+	}
+	r.cancelMutex.Unlock()
+
+	switch state {
+	case ConsumerStateReady, ConsumerStateCancelled:
+		return r.StartConsuming()
+	case ConsumerStateConsuming:
+		r.Restart()
+	}
+	return nil
+}
+
+// StartConsuming consumes every partition of the topic, one goroutine per partition.
+// Cancel() and Restart() stop all these goroutines together.
 func (r *CommonConsumer) StartConsuming() error {
-	if r.state != ConsumerStateReady {
-		msg := fmt.Sprintf("Try to start a consumer which is not ready. state=%d, topic=%v", r.state, r.topic)
-		Error(msg)
+	r.cancelMutex.Lock()
+	if (r.state != ConsumerStateReady && r.state != ConsumerStateCancelled) || r.canc != nil {
+		r.cancelMutex.Unlock()
+		msg := fmt.Sprintf("Try to start a consumer which is not ready. state=%d, topic=%v", r.State(), r.topic)
+		consumerLog().Error(msg)
 		return ErrCantStartConsumer(msg)
 	}
-	if r.canc == nil {
-		// 调用场景不存在竞争，因此没加锁。
-		r.canc = make(chan struct{}, 1)
-	}
+	r.canc = make(chan struct{}, 1)
 	cancel := r.canc
+	r.cancelMutex.Unlock()
 
-	offset := r.LastOffset() + 1
+	if r.cg != nil {
+		return r.startGroupConsuming(cancel)
+	}
 
-	partitionConsumer, err := r.sc.ConsumePartition(r.topic, 0, offset)
+	partitions, err := r.sc.Partitions(r.topic)
 	if err != nil {
-		Errorf("Failed to create partitionConsumer topic=%v; error=%v", r.topic, err)
+		consumerLog().Errorf("Failed to get partitions topic=%v; error=%v", r.topic, err)
+		r.didFailToStart()
 		return err
 	}
-	r.state = ConsumerStateConsuming
-	Infof("Subscribed %v", r.topic)
+
+	r.offsetsMutex.Lock()
+	r.stoppedPartitions = nil
+	r.offsetsMutex.Unlock()
+
+	partitionConsumers := make(map[int32]sarama.PartitionConsumer, len(partitions))
+	for _, partition := range partitions {
+		partitionConsumer, err := r.sc.ConsumePartition(r.topic, partition, r.startOffset(partition))
+		if err != nil {
+			consumerLog().Errorf("Failed to create partitionConsumer topic=%v; partition=%d; error=%v", r.topic, partition, err)
+			for p, pc := range partitionConsumers {
+				if err := pc.Close(); err != nil {
+					consumerLog().Errorf("Failed to close partitionConsumer topic=%v; partition=%d; err=%v", r.topic, p, err)
+				}
+			}
+			r.didFailToStart()
+			return err
+		}
+		partitionConsumers[partition] = partitionConsumer
+		r.setWatermark(partition, partitionConsumer)
+		if r.LastOffsetOfPartition(partition) < 0 {
+			r.setLastOffset(partition, -1)
+		}
+	}
+	r.didStartConsuming()
+	consumerLog().Infof("Subscribed %v; partitions=%v", r.topic, partitions)
 
 	saveConsumer(r)
+
+	var wg sync.WaitGroup
+	for partition, partitionConsumer := range partitionConsumers {
+		wg.Add(1)
+		go r.consumePartition(partition, partitionConsumer, cancel, &wg)
+	}
+
 	go func() {
-		defer func() {
-			Errorf("closing partitionConsumer topic=%v;", r.topic)
-			if err := partitionConsumer.Close(); err != nil {
-				Errorf("Failed to close partitionConsumer topic=%v; err=%v", r.topic, err)
+		wg.Wait()
+		r.didStopConsuming()
+	}()
+	return nil
+}
+
+func (r *CommonConsumer) handle(ctx *CTX, msg *sarama.ConsumerMessage) {
+	if cc, ok := r.Consumer.(CTXConsumer); ok {
+		cc.HandleWithCTX(ctx, msg)
+		return
+	}
+	r.Handle(msg)
+}
+
+// didFailToStart makes r ready to start again.
+func (r *CommonConsumer) didFailToStart() {
+	r.cancelMutex.Lock()
+	defer r.cancelMutex.Unlock()
+	r.canc = nil
+}
+
+func (r *CommonConsumer) didStartConsuming() {
+	r.cancelMutex.Lock()
+	defer r.cancelMutex.Unlock()
+	r.stopped = make(chan struct{})
+	r.state = ConsumerStateConsuming
+}
+
+// didStopConsuming must be called after all consuming goroutines stopped.
+// r stays registered, so that it can be started again by StartOrRestart, e.g. by RestartConsumerHandler.
+func (r *CommonConsumer) didStopConsuming() {
+	r.cancelMutex.Lock()
+	close(r.stopped)
+	restart := r.state == ConsumerStateRequireRestart
+	if r.state == ConsumerStateCancelling {
+		r.state = ConsumerStateCancelled
+	} else {
+		r.state = ConsumerStateReady
+	}
+	r.canc = nil
+	r.cancelMutex.Unlock()
+	if restart {
+		// Not MustStartConsuming, since nothing recovers a panic here. r stays ready if Kafka is unreachable.
+		if err := r.StartConsuming(); err != nil {
+			r.setLastError(fmt.Sprintf("failed to restart: %v", err))
+		}
+	}
+}
+
+func (r *CommonConsumer) consumePartition(partition int32, partitionConsumer sarama.PartitionConsumer, cancel <-chan struct{}, wg *sync.WaitGroup) {
+	defer func() {
+		consumerLog().Errorf("closing partitionConsumer topic=%v; partition=%d;", r.topic, partition)
+		if err := partitionConsumer.Close(); err != nil {
+			consumerLog().Errorf("Failed to close partitionConsumer topic=%v; partition=%d; err=%v", r.topic, partition, err)
+		}
+		wg.Done()
+	}()
+
+	for {
+		select {
+		case msg, ok := <-partitionConsumer.Messages():
+			if !ok {
+				return
 			}
-			deleteConsumer(r)
-			if r.state == ConsumerStateRequireRestart {
-				r.state = ConsumerStateReady
-				r.MustStartConsuming()
+			if r.State() != ConsumerStateConsuming {
+				continue
 			}
-		}()
-
-	Loop:
-		for {
-			select {
-			case msg := <-partitionConsumer.Messages():
-				if r.state != ConsumerStateConsuming {
-					continue
-				}
-				r.Handle(msg)
-			case <-cancel:
-				// Cancel() is invoked
-				break Loop
+			if !r.handleWithRetry(msg, cancel) {
+				// The message is consumed again after Restart(), from the last handled offset.
+				r.stopPartition(partition)
+				return
 			}
+			r.setLastOffset(partition, msg.Offset)
+		case <-cancel:
+			// Cancel() is invoked
+			return
 		}
-	}()
-	return nil
+	}
 }
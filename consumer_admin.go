@@ -0,0 +1,158 @@
+package u
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConsumerSnapshot is a point-in-time view of a registered CommonConsumer.
+type ConsumerSnapshot struct {
+	Topic      string              `json:"topic"`
+	Group      bool                `json:"group"`
+	State      ConsumerState       `json:"state"`
+	Partitions []PartitionSnapshot `json:"partitions"`
+	// Handled is the number of messages handled without panic.
+	Handled     int64      `json:"handled"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+type PartitionSnapshot struct {
+	Partition int32 `json:"partition"`
+	// Offset of the last handled message. -1 if none.
+	Offset int64 `json:"offset"`
+	// HighWaterMark is the offset that will be used for the next message produced to the partition.
+	HighWaterMark int64 `json:"high_water_mark"`
+	// Lag is the number of messages not handled yet. -1 if unknown.
+	Lag int64 `json:"lag"`
+	// Stopped is true if consuming of the partition stopped at a message that could not be handled. See NewConsumer.
+	Stopped bool `json:"stopped,omitempty"`
+}
+
+// AllConsumers returns snapshots of all registered consumers, sorted by topic.
+func AllConsumers() []ConsumerSnapshot {
+	allConsumersMutex.RLock()
+	consumers := make([]*CommonConsumer, 0, len(allConsumers))
+	for _, c := range allConsumers {
+		consumers = append(consumers, c)
+	}
+	allConsumersMutex.RUnlock()
+
+	snapshots := make([]ConsumerSnapshot, 0, len(consumers))
+	for _, c := range consumers {
+		snapshots = append(snapshots, c.Snapshot())
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Topic < snapshots[j].Topic })
+	return snapshots
+}
+
+// Snapshot returns a point-in-time view of r.
+func (r *CommonConsumer) Snapshot() ConsumerSnapshot {
+	s := ConsumerSnapshot{
+		Topic:   r.topic,
+		Group:   r.cg != nil,
+		State:   r.State(),
+		Handled: atomic.LoadInt64(&r.handled),
+	}
+
+	r.lastErrLock.Lock()
+	if r.lastErr != "" {
+		at := r.lastErrAt
+		s.LastError = r.lastErr
+		s.LastErrorAt = &at
+	}
+	r.lastErrLock.Unlock()
+
+	r.offsetsMutex.RLock()
+	defer r.offsetsMutex.RUnlock()
+	s.Partitions = make([]PartitionSnapshot, 0, len(r.offsets))
+	for p, offset := range r.offsets {
+		ps := PartitionSnapshot{
+			Partition:     p,
+			Offset:        offset,
+			HighWaterMark: -1,
+			Lag:           -1,
+			Stopped:       r.stoppedPartitions[p],
+		}
+		if hwm, ok := r.watermarks[p]; ok {
+			ps.HighWaterMark = hwm.HighWaterMarkOffset()
+			if offset >= 0 && ps.HighWaterMark >= 0 {
+				ps.Lag = MaxInt64(ps.HighWaterMark-offset-1, 0)
+			}
+		}
+		s.Partitions = append(s.Partitions, ps)
+	}
+	sort.Slice(s.Partitions, func(i, j int) bool { return s.Partitions[i].Partition < s.Partitions[j].Partition })
+	return s
+}
+
+func (r *CommonConsumer) setLastError(err interface{}) {
+	r.lastErrLock.Lock()
+	defer r.lastErrLock.Unlock()
+	r.lastErr = fmt.Sprintf("%v", err)
+	r.lastErrAt = time.Now()
+}
+
+// RegisterConsumerAdminRoutes registers routes for operating consumers at runtime.
+//
+//	GET  /consumers                 lists snapshots of all consumers
+//	POST /consumers/:topic/cancel   cancels the consumer of topic
+//	POST /consumers/:topic/restart  restarts the consumer of topic
+//
+// Authorization is up to the caller. Example:
+//
+//	RegisterConsumerAdminRoutes(router.Group("/admin", authMiddleware))
+func RegisterConsumerAdminRoutes(routes gin.IRoutes) {
+	routes.GET("/consumers", ListConsumersHandler)
+	routes.POST("/consumers/:topic/cancel", CancelConsumerHandler)
+	routes.POST("/consumers/:topic/restart", RestartConsumerHandler)
+}
+
+// ListConsumersHandler responds {"error": null, "consumers": [ConsumerSnapshot...]}.
+func ListConsumersHandler(c *gin.Context) {
+	h := NewGinHelper(c)
+	h.RespondKV200("consumers", AllConsumers(), nil)
+}
+
+// CancelConsumerHandler cancels the consumer of path parameter "topic".
+func CancelConsumerHandler(c *gin.Context) {
+	h := NewGinHelper(c)
+	consumer, erro := consumerForTopicParam(h)
+	if erro != nil {
+		h.RespondError(erro)
+		return
+	}
+	h.L().Infof("Cancel consumer by admin. topic=%v", consumer.Topic())
+	consumer.Cancel()
+	h.RespondKV200("consumer", consumer.Snapshot(), nil)
+}
+
+// RestartConsumerHandler restarts the consumer of path parameter "topic", or starts it again if it has been cancelled.
+func RestartConsumerHandler(c *gin.Context) {
+	h := NewGinHelper(c)
+	consumer, erro := consumerForTopicParam(h)
+	if erro != nil {
+		h.RespondError(erro)
+		return
+	}
+	h.L().Infof("Restart consumer by admin. topic=%v", consumer.Topic())
+	if err := consumer.StartOrRestart(); err != nil {
+		if erro = TryConvertToErrorType(err); erro == nil {
+			erro = ErrConsumerError(err)
+		}
+	}
+	h.RespondKV200("consumer", consumer.Snapshot(), erro)
+}
+
+func consumerForTopicParam(h *GinHelper) (*CommonConsumer, ErrorType) {
+	topic := h.Param("topic")
+	consumer := ConsumerForTopic(topic)
+	if consumer == nil {
+		return nil, ErrConsumerNotFound(fmt.Sprintf("No consumer for topic %v", topic))
+	}
+	return consumer, nil
+}
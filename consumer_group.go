@@ -0,0 +1,116 @@
+package u
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Shopify/sarama"
+)
+
+// NewGroupConsumer creates a CommonConsumer which consumes the topic as a member of the consumer group.
+// Unlike NewConsumer, Consumer.LastOffset() is not used. Offsets are committed to Kafka after each successful Handle,
+// so replicas of a service share partitions of the topic instead of each reading the whole topic.
+// Errors of cg are not drained by CommonConsumer. Read cg.Errors() if Consumer.Return.Errors is enabled.
+func NewGroupConsumer(topic string, cg sarama.ConsumerGroup) CommonConsumer {
+	return CommonConsumer{
+		topic: topic,
+		cg:    cg,
+		CTX:   NewContext(),
+	}
+}
+
+func (r *CommonConsumer) startGroupConsuming(cancel <-chan struct{}) error {
+	ctx, cancelFunc := context.WithCancel(context.Background())
+
+	r.didStartConsuming()
+	consumerLog().Infof("Joined consumer group for %v", r.topic)
+
+	saveConsumer(r)
+
+	go func() {
+		select {
+		case <-cancel:
+			// Cancel() is invoked
+		case <-ctx.Done():
+		}
+		cancelFunc()
+	}()
+
+	go func() {
+		defer func() {
+			consumerLog().Errorf("leaving consumer group topic=%v;", r.topic)
+			cancelFunc()
+			r.didStopConsuming()
+		}()
+
+		handler := groupConsumerHandler{r}
+		for {
+			// Consume returns when a rebalance happens. Call it again to join the new session.
+			err := r.cg.Consume(ctx, []string{r.topic}, handler)
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+				return
+			}
+			if err != nil {
+				consumerLog().Errorf("Failed to consume as group member topic=%v; err=%v", r.topic, err)
+			}
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// groupConsumerHandler implements sarama.ConsumerGroupHandler.
+type groupConsumerHandler struct {
+	r *CommonConsumer
+}
+
+func (h groupConsumerHandler) Setup(session sarama.ConsumerGroupSession) error {
+	partitions := session.Claims()[h.r.topic]
+	consumerLog().Infof("Consumer group session started topic=%v; memberID=%v; generationID=%d; partitions=%v",
+		h.r.topic, session.MemberID(), session.GenerationID(), partitions)
+
+	// Partitions may be reassigned after a rebalance. Only track those claimed by this session.
+	h.r.offsetsMutex.Lock()
+	defer h.r.offsetsMutex.Unlock()
+	h.r.offsets = make(map[int32]int64, len(partitions))
+	h.r.watermarks = make(map[int32]highWaterMarker, len(partitions))
+	h.r.stoppedPartitions = nil
+	for _, p := range partitions {
+		h.r.offsets[p] = -1
+	}
+	return nil
+}
+
+func (h groupConsumerHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	consumerLog().Infof("Consumer group session ended topic=%v; memberID=%v; generationID=%d",
+		h.r.topic, session.MemberID(), session.GenerationID())
+	return nil
+}
+
+func (h groupConsumerHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	h.r.setWatermark(claim.Partition(), claim)
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			if h.r.State() != ConsumerStateConsuming {
+				continue
+			}
+			if !h.r.handleWithRetry(msg, session.Context().Done()) {
+				// Stop the claim without committing. The message is consumed again in the next session.
+				h.r.stopPartition(msg.Partition)
+				return fmt.Errorf("stopped consuming topic=%v; partition=%d at offset=%d", msg.Topic, msg.Partition, msg.Offset)
+			}
+			h.r.setLastOffset(msg.Partition, msg.Offset)
+			session.MarkMessage(msg, "")
+			session.Commit()
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package u
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"go.uber.org/zap"
+)
+
+// Record header keys of messages sent to dead-letter topic.
+const (
+	DeadLetterErrorHeader     = "dlt.error"
+	DeadLetterTopicHeader     = "dlt.topic"
+	DeadLetterPartitionHeader = "dlt.partition"
+	DeadLetterOffsetHeader    = "dlt.offset"
+)
+
+// RetryPolicy decides what CommonConsumer does when Handle panics.
+// The zero value means handle once, then stop consuming the partition without committing the message,
+// so that it is consumed again after Restart(). Meanwhile the partition is Stopped in Snapshot.
+// Set DeadLetterTopic to move on to the next message instead.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of calls to Handle for one message, including the first one.
+	// Values less than 1 are treated as 1.
+	MaxAttempts int
+
+	// Backoff is the duration to wait before the second attempt. It doubles after each attempt.
+	Backoff time.Duration
+
+	// MaxBackoff caps Backoff. Zero means no cap.
+	MaxBackoff time.Duration
+
+	// DeadLetterTopic receives messages still failing after MaxAttempts. Optional.
+	// The record carries key and value of the original message,
+	// with the error, original topic, partition, offset and trace ID in headers.
+	DeadLetterTopic string
+
+	// DeadLetterProducer is required if DeadLetterTopic is set.
+	DeadLetterProducer sarama.SyncProducer
+}
+
+// backoff returns duration to wait before the attempt. attempt starts from 1.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	if attempt <= 1 || p.Backoff <= 0 {
+		return 0
+	}
+	d := p.Backoff
+	for i := 2; i < attempt; i++ {
+		d *= 2
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return d
+}
+
+// SetRetryPolicy must be called before StartConsuming.
+func (r *CommonConsumer) SetRetryPolicy(policy RetryPolicy) {
+	r.retryPolicy = policy
+}
+
+func (r *CommonConsumer) RetryPolicy() RetryPolicy {
+	return r.retryPolicy
+}
+
+// handleWithRetry calls handle until it does not panic or MaxAttempts is reached.
+// Then the message is sent to DeadLetterTopic if any.
+// It returns true if the message is handled or sent to DeadLetterTopic, so that its offset can be committed.
+// Otherwise, the caller must stop consuming the partition without committing the message.
+// Waiting for backoff is abandoned once cancel is closed.
+func (r *CommonConsumer) handleWithRetry(msg *sarama.ConsumerMessage, cancel <-chan struct{}) bool {
+	policy := r.retryPolicy
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	ctx := CTXFromConsumerMessage(msg)
+	traceID := ctx.TraceID()
+	if TraceIDFromConsumerMessage(msg) == "" {
+		// Keep the generated trace ID in msg, so that MessageLogger gives the same one.
+		msg.Headers = append(msg.Headers, &sarama.RecordHeader{Key: []byte(KafkaTraceIDHeader), Value: []byte(traceID)})
+	}
+	log := messageLogger(ctx, msg)
+	var err interface{}
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if d := policy.backoff(attempt); d > 0 {
+			timer := time.NewTimer(d)
+			select {
+			case <-timer.C:
+			case <-cancel:
+				timer.Stop()
+				log.Warnf("Cancelled before attempt=%d/%d. Message is not committed", attempt, maxAttempts)
+				return false
+			}
+		}
+		err = r.tryHandle(ctx, msg)
+		if err == nil {
+			atomic.AddInt64(&r.handled, 1)
+			return true
+		}
+		r.setLastError(err)
+		log.Errorf("Failed to handle message attempt=%d/%d; error=%v", attempt, maxAttempts, err)
+	}
+
+	if policy.DeadLetterTopic != "" && r.sendToDeadLetterTopic(log, traceID, msg, err) {
+		return true
+	}
+	r.setLastError(fmt.Sprintf("partition %d stopped at offset %d: %v", msg.Partition, msg.Offset, err))
+	log.Error("Message is neither handled nor sent to dead-letter topic. Stop consuming the partition without committing it")
+	return false
+}
+
+// tryHandle returns the recovered value if handle panics.
+func (r *CommonConsumer) tryHandle(ctx *CTX, msg *sarama.ConsumerMessage) (err interface{}) {
+	defer func() {
+		err = recover()
+	}()
+	r.handle(ctx, msg)
+	return nil
+}
+
+// sendToDeadLetterTopic returns true if the message is sent.
+func (r *CommonConsumer) sendToDeadLetterTopic(log *zap.SugaredLogger, traceID string, msg *sarama.ConsumerMessage, cause interface{}) bool {
+	policy := r.retryPolicy
+	if policy.DeadLetterProducer == nil {
+		log.Error("DeadLetterProducer is nil")
+		return false
+	}
+
+	dlm := &sarama.ProducerMessage{
+		Topic:   policy.DeadLetterTopic,
+		Key:     sarama.ByteEncoder(msg.Key),
+		Value:   sarama.ByteEncoder(msg.Value),
+		Headers: make([]sarama.RecordHeader, 0, len(msg.Headers)+5),
+	}
+	for _, h := range msg.Headers {
+		if h != nil {
+			dlm.Headers = append(dlm.Headers, *h)
+		}
+	}
+	dlm.Headers = append(dlm.Headers,
+		sarama.RecordHeader{Key: []byte(DeadLetterErrorHeader), Value: []byte(fmt.Sprintf("%v", cause))},
+		sarama.RecordHeader{Key: []byte(DeadLetterTopicHeader), Value: []byte(msg.Topic)},
+		sarama.RecordHeader{Key: []byte(DeadLetterPartitionHeader), Value: []byte(strconv.FormatInt(int64(msg.Partition), 10))},
+		sarama.RecordHeader{Key: []byte(DeadLetterOffsetHeader), Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+	)
+	AppendTraceIDToProducerMessage(dlm, traceID)
+
+	if _, _, err := policy.DeadLetterProducer.SendMessage(dlm); err != nil {
+		log.Errorf("Failed to send message to dead-letter topic=%v; err=%v", policy.DeadLetterTopic, err)
+		return false
+	}
+	log.Warnf("Message sent to dead-letter topic=%v", policy.DeadLetterTopic)
+	return true
+}
@@ -2,37 +2,155 @@ package u
 
 import (
 	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
 	"sync"
+	"time"
 
 	"google.golang.org/grpc/metadata"
 )
 
 // CTX is short for Context. Name is different from context.Context or gin.Context for preventing confusion.
+// CTX implements context.Context by wrapping a parent context, so deadlines and cancellation flow into
+// GRPC calls, SQL queries and Mongo operations made with it.
 type CTX struct {
+	// parent is the wrapped context.Context. nil means context.Background().
+	parent context.Context
+
 	// traceID could be used for tracing call chain through services.
 	// Developer should try to put value of traceID in log.
 	traceID     string
 	traceIDOnce sync.Once
 
+	// spanID identifies the work done by this service in the trace. parentSpanID is the span of the caller.
+	// See TraceContext.
+	spanID       string
+	spanIDOnce   sync.Once
+	parentSpanID string
+	traceState   string
+	notSampled   bool
+
 	// Sometimes developer feels difficult to choose between panic and return-error.
 	// In this case, try using PoR or PoRErr.
 	// PreferPanic is a preference to PoR and PoRErr.
 	PreferPanic bool
 
-	// See Set and Get.
-	kv map[string]interface{}
+	// See Set and Get. Created lazily so that CTX literals work.
+	kv     *kvStore
+	kvOnce sync.Once
+}
+
+// kvStore is shared by a CTX and its copies made by WithContext.
+type kvStore struct {
+	sync.RWMutex
+	m map[string]interface{}
+}
+
+func (c *CTX) store() *kvStore {
+	c.kvOnce.Do(func() {
+		if c.kv == nil {
+			c.kv = &kvStore{m: map[string]interface{}{}}
+		}
+	})
+	return c.kv
+}
+
+// Context returns the wrapped context.Context.
+func (c *CTX) Context() context.Context {
+	if c.parent == nil {
+		return context.Background()
+	}
+	return c.parent
+}
+
+// WithContext returns a copy of c that wraps parent. Trace ID, PreferPanic and key/value pairs are shared.
+// Example:
+//
+//	timeoutCtx, cancel := context.WithTimeout(ctx, time.Second)
+//	defer cancel()
+//	c := ctx.WithContext(timeoutCtx)
+func (c *CTX) WithContext(parent context.Context) *CTX {
+	return &CTX{
+		parent:       parent,
+		traceID:      c.TraceID(),
+		spanID:       c.SpanID(),
+		parentSpanID: c.parentSpanID,
+		traceState:   c.traceState,
+		notSampled:   c.notSampled,
+		PreferPanic:  c.PreferPanic,
+		kv:           c.store(),
+	}
+}
+
+// Deadline implementation to context.Context.
+func (c *CTX) Deadline() (deadline time.Time, ok bool) {
+	return c.Context().Deadline()
+}
+
+// Done implementation to context.Context.
+func (c *CTX) Done() <-chan struct{} {
+	return c.Context().Done()
+}
+
+// Err implementation to context.Context.
+func (c *CTX) Err() error {
+	return c.Context().Err()
+}
+
+// Value implementation to context.Context.
+// Values stored by Set take precedence over values of the wrapped context.
+func (c *CTX) Value(key any) any {
+	if k, ok := key.(string); ok {
+		if v, ok := c.lookup(k); ok {
+			return v
+		}
+	}
+	return c.Context().Value(key)
 }
 
 // TraceID returns TraceID. Create one if not.
 func (c *CTX) TraceID() string {
-	if c.traceID == "" {
-		c.traceIDOnce.Do(func() {
-			c.traceID = UUID12()
-		})
-	}
+	c.traceIDOnce.Do(func() {
+		if c.traceID == "" {
+			c.traceID = NewTraceID()
+		}
+	})
 	return c.traceID
 }
 
+// SpanID returns the span ID of this service. Create one if not.
+func (c *CTX) SpanID() string {
+	c.spanIDOnce.Do(func() {
+		if c.spanID == "" {
+			c.spanID = NewSpanID()
+		}
+	})
+	return c.spanID
+}
+
+// ParentSpanID returns the span ID of the caller. Returns "" if unknown.
+func (c *CTX) ParentSpanID() string {
+	return c.parentSpanID
+}
+
+// TraceState returns W3C tracestate received from the caller.
+func (c *CTX) TraceState() string {
+	return c.traceState
+}
+
+// Sampled returns false if the caller asked not to sample this trace.
+func (c *CTX) Sampled() bool {
+	return !c.notSampled
+}
+
+func (c *CTX) setTraceContext(tc TraceContext) {
+	c.traceID = tc.TraceID
+	c.parentSpanID = tc.SpanID
+	c.traceState = tc.TraceState
+	c.notSampled = tc.NotSampled
+}
+
 // PoR Panic or return the error referred by PreferPanic.
 func (c *CTX) PoR(erro ErrorType) ErrorType {
 	if c.PreferPanic {
@@ -51,33 +169,107 @@ func (c *CTX) PoRErr(err error) error {
 
 // Get returns the value for the given key.
 // If the value does not exist it returns nil.
+// It is safe to call Get and Set concurrently.
 func (c *CTX) Get(key string) interface{} {
-	v, ok := c.kv[key]
-	if !ok {
-		return nil
-	}
+	v, _ := c.lookup(key)
 	return v
 }
 
+func (c *CTX) lookup(key string) (interface{}, bool) {
+	s := c.store()
+	s.RLock()
+	defer s.RUnlock()
+	v, ok := s.m[key]
+	return v, ok
+}
+
 // Set is used to store a new key/value pair exclusively for this context.
+// It is safe to call Get and Set concurrently.
 func (c *CTX) Set(key string, value interface{}) {
-	c.kv[key] = value
+	s := c.store()
+	s.Lock()
+	defer s.Unlock()
+	s.m[key] = value
+}
+
+// CTXValue returns the value for the given key if it exists and is of type T.
+// Values set by SetValuesFromIncomingContext are strings, whatever their types were on the caller's side.
+// Example:
+//
+//	userID, ok := CTXValue[int64](ctx, "user_id")
+//	userIDOfCaller, ok := CTXValue[string](ctx, "user_id") // after SetValuesFromIncomingContext
+func CTXValue[T any](c *CTX, key string) (value T, ok bool) {
+	v, exists := c.lookup(key)
+	if !exists {
+		return
+	}
+	value, ok = v.(T)
+	return
+}
+
+// CTXValueOr returns the value for the given key. Returns defaultValue if the key does not exist or the value is not of type T.
+func CTXValueOr[T any](c *CTX, key string, defaultValue T) T {
+	if v, ok := CTXValue[T](c, key); ok {
+		return v
+	}
+	return defaultValue
+}
+
+// CTXMetadataPrefix prefixes keys of CTX values in GRPC metadata.
+// Keys are hex encoded after the prefix, since GRPC metadata keys are lower case and limited to a few characters.
+const CTXMetadataPrefix = "ctx-"
+
+// AppendValuesToOutgoingContext appends values of keys to outgoing metadata of context.
+// Keys are kept as they are. Values are formatted with %v, so the callee gets strings. Keys that do not exist are skipped.
+func (c *CTX) AppendValuesToOutgoingContext(context context.Context, keys ...string) context.Context {
+	kv := make([]string, 0, len(keys)*2)
+	for _, key := range keys {
+		v, ok := c.lookup(key)
+		if !ok {
+			continue
+		}
+		kv = append(kv, CTXMetadataPrefix+hex.EncodeToString([]byte(key)), fmt.Sprintf("%v", v))
+	}
+	if len(kv) == 0 {
+		return context
+	}
+	return metadata.AppendToOutgoingContext(context, kv...)
 }
 
-// CreateGRPCContext create a context.Context with header "tid".
+// SetValuesFromIncomingContext sets values appended by AppendValuesToOutgoingContext. Values are strings. See CTXValue.
+func (c *CTX) SetValuesFromIncomingContext(context context.Context) {
+	md, ok := metadata.FromIncomingContext(context)
+	if !ok {
+		return
+	}
+	for k, vs := range md {
+		if !strings.HasPrefix(k, CTXMetadataPrefix) || len(vs) == 0 {
+			continue
+		}
+		key, err := hex.DecodeString(strings.TrimPrefix(k, CTXMetadataPrefix))
+		if err != nil {
+			continue
+		}
+		c.Set(string(key), vs[0])
+	}
+}
+
+// CreateGRPCContext create a context.Context with headers "traceparent", "b3" and "tid".
+// Deadline and cancellation of the wrapped context are kept.
 func (c *CTX) CreateGRPCContext() context.Context {
-	ctx := context.Background()
-	return c.FillGRPCContext(ctx)
+	return c.FillGRPCContext(c.Context())
 }
 
-// FillGRPCContext append "tid" to context.Context .
+// FillGRPCContext append "traceparent", "tracestate", "b3" and "tid" to context.Context .
+// Span ID of c is the parent span of the callee.
 func (c *CTX) FillGRPCContext(context context.Context) context.Context {
-	return ContextByAppendingTraceID(context, c.traceID)
+	return metadata.AppendToOutgoingContext(context, traceHeaders(c.TraceID(), c.SpanID(), c.traceState, c.notSampled)...)
 }
 
+// ContextByAppendingTraceID append "traceparent", "b3" and "tid" to context.Context with a new span ID.
+// traceparent and b3 are omitted if traceID is not hex, which may be the case of legacy trace IDs.
 func ContextByAppendingTraceID(context context.Context, traceID string) context.Context {
-	context = metadata.AppendToOutgoingContext(context, "tid", traceID)
-	return context
+	return metadata.AppendToOutgoingContext(context, traceHeaders(traceID, NewSpanID(), "", false)...)
 }
 
 //func CreateGRPCOutgoingContext(in context.Context) context.Context {
@@ -86,7 +278,7 @@ func ContextByAppendingTraceID(context context.Context, traceID string) context.
 //	return out
 //}
 
-// TraceIDFromOutgoing extract tid from context. return "" if not found.
+// TraceIDFromOutgoing extract trace ID from context. return "" if not found. See TraceIDFromMD.
 func TraceIDFromOutgoing(context context.Context) string {
 	md, ok := metadata.FromOutgoingContext(context)
 	if ok {
@@ -95,7 +287,7 @@ func TraceIDFromOutgoing(context context.Context) string {
 	return ""
 }
 
-// TraceIDFromIncoming extract tid from context. return "" if not found.
+// TraceIDFromIncoming extract trace ID from context. return "" if not found. See TraceIDFromMD.
 func TraceIDFromIncoming(context context.Context) string {
 	md, ok := metadata.FromIncomingContext(context)
 	if ok {
@@ -104,18 +296,15 @@ func TraceIDFromIncoming(context context.Context) string {
 	return ""
 }
 
+// TraceIDFromMD extract trace ID from "traceparent", "b3", "x-b3-traceid" or "tid" in that order. return "" if not found.
 func TraceIDFromMD(md metadata.MD) string {
-	traceID := md.Get("tid")
-	if len(traceID) > 0 {
-		return traceID[0]
-	}
-	return ""
+	tc, _ := TraceContextFromMD(md)
+	return tc.TraceID
 }
 
 func NewContext() *CTX {
 	return &CTX{
 		PreferPanic: true,
-		kv:          map[string]interface{}{},
 	}
 }
 
@@ -125,9 +314,21 @@ func NewCTXWithTraceID(traceID string) *CTX {
 	return c
 }
 
-func NewCTXWithGRPCContext(context context.Context) *CTX {
-	traceID := TraceIDFromIncoming(context)
+// NewCTXWithContext creates a CTX wrapping parent.
+func NewCTXWithContext(parent context.Context) *CTX {
 	c := NewContext()
-	c.traceID = traceID
+	c.parent = parent
+	return c
+}
+
+// NewCTXWithGRPCContext creates a CTX wrapping the incoming context, with trace context and values from its metadata.
+// See AppendValuesToOutgoingContext.
+func NewCTXWithGRPCContext(context context.Context) *CTX {
+	c := NewCTXWithContext(context)
+	if md, ok := metadata.FromIncomingContext(context); ok {
+		tc, _ := TraceContextFromMD(md)
+		c.setTraceContext(tc)
+	}
+	c.SetValuesFromIncomingContext(context)
 	return c
 }
@@ -0,0 +1,53 @@
+package u
+
+import (
+	"github.com/Shopify/sarama"
+	"go.uber.org/zap"
+)
+
+// CTXLogKeys are keys of CTX values attached to loggers returned by CTX.L and CTX.Logger.
+// "tid" is always attached. Keys absent from a CTX are skipped.
+var CTXLogKeys []string
+
+// Logger returns Logger with "tid" and values of CTXLogKeys attached.
+func (c *CTX) Logger() *zap.Logger {
+	return c.loggerFrom(Logger)
+}
+
+// L returns a sugared Logger with "tid" and values of CTXLogKeys attached.
+// Example:
+//
+//	ctx.L().Infof("user %v signed in", userID)
+func (c *CTX) L() *zap.SugaredLogger {
+	return c.Logger().Sugar()
+}
+
+func (c *CTX) loggerFrom(logger *zap.Logger) *zap.Logger {
+	fields := make([]zap.Field, 0, len(CTXLogKeys)+1)
+	fields = append(fields, zap.String("tid", c.TraceID()))
+	for _, key := range CTXLogKeys {
+		if v, ok := c.lookup(key); ok {
+			fields = append(fields, zap.Any(key, v))
+		}
+	}
+	return logger.With(fields...)
+}
+
+// L returns the logger of CTX of the request. See CTX.L.
+func (r *GinHelper) L() *zap.SugaredLogger {
+	return r.CTX().L()
+}
+
+// MessageLogger returns a logger with "tid" of msg and values of CTXLogKeys attached, along with topic, partition and offset.
+// Trace ID is the same as the one of CTX passed to CTXConsumer.HandleWithCTX.
+func (r *CommonConsumer) MessageLogger(msg *sarama.ConsumerMessage) *zap.SugaredLogger {
+	return messageLogger(CTXFromConsumerMessage(msg), msg)
+}
+
+func messageLogger(ctx *CTX, msg *sarama.ConsumerMessage) *zap.SugaredLogger {
+	return ctx.loggerFrom(SubsystemLogger(LogSubsystemConsumer)).With(
+		zap.String("topic", msg.Topic),
+		zap.Int32("partition", msg.Partition),
+		zap.Int64("offset", msg.Offset),
+	).Sugar()
+}
@@ -1,11 +1,15 @@
 package u
 
-import "github.com/SimpleFelix/esg"
+import (
+	"errors"
 
-type ErrorType = esg.ErrorType
+	"github.com/SimpleFelix/esg"
+)
 
-var notWorthLogging byte
-var printErrAsInfo byte
+// ErrorType is implemented by error types in Err*.go and AnyError.go. They were scaffolded by ESG (github.com/simplefelix/esg),
+// and are maintained by hand since, because ESG does not generate stacks, Unwrap or the pointer receiver of SetExtra.
+// Copy ErrConflict.go to add one instead of running ESG.
+type ErrorType = esg.ErrorType
 
 // TryConvertToErrorType returns a ErrorType if err is a ErrorType. returns nil if not
 func TryConvertToErrorType(err interface{}) ErrorType {
@@ -16,10 +20,97 @@ func TryConvertToErrorType(err interface{}) ErrorType {
 	return nil
 }
 
+// ErrorFlag is a bit of ErrorMeta.Flags.
+type ErrorFlag uint
+
+const (
+	// ErrFlagNoNeedToLog stops respondError from logging the error unless gin is in debug mode.
+	ErrFlagNoNeedToLog ErrorFlag = 1 << iota
+	// ErrFlagPrintAsInfo makes respondError log the error at info level.
+	ErrFlagPrintAsInfo
+	// ErrFlagRetryable tells clients that the request can be retried. See ErrorPayload.Retryable.
+	ErrFlagRetryable
+	// ErrFlagExposeDetails responds custom fields and causes of the error in ErrorPayload.Details.
+	ErrFlagExposeDetails
+)
+
+// ErrorMeta is kept by Extra of error types. Modifiers below set it. Set it by SetExtra only if you know what you are doing.
+// Modifiers never change an ErrorMeta in place, since copies of an error share it.
+type ErrorMeta struct {
+	Flags ErrorFlag
+	// Fields are logged by respondError, and responded if ErrFlagExposeDetails is set.
+	Fields map[string]interface{}
+}
+
+// ErrorMetaOf returns a copy of metadata of erro. Zero value if erro has none.
+func ErrorMetaOf(erro ErrorType) ErrorMeta {
+	return errorMetaOf(erro)
+}
+
+// errorMetaOf accepts esg.ErrorTypeWriteable too, which has no Extra in its method set.
+func errorMetaOf(erro interface{}) ErrorMeta {
+	e, ok := erro.(interface{ Extra() interface{} })
+	if !ok || IsValueNil(e) {
+		return ErrorMeta{}
+	}
+	meta, ok := e.Extra().(*ErrorMeta)
+	if !ok || meta == nil {
+		return ErrorMeta{}
+	}
+	return *meta
+}
+
+// HasErrorFlag reports whether flag is set on erro.
+func HasErrorFlag(erro ErrorType, flag ErrorFlag) bool {
+	return ErrorMetaOf(erro).Flags&flag != 0
+}
+
+// SetErrorFlags adds flags to erro, keeping flags and fields set before.
+// erro must be a pointer, e.g.
+//
+//	erro := u.ErrConflict(err)
+//	u.SetErrorFlags(&erro, u.ErrFlagNoNeedToLog|u.ErrFlagRetryable)
+func SetErrorFlags(erro esg.ErrorTypeWriteable, flags ErrorFlag) {
+	meta := errorMetaOf(erro)
+	meta.Flags |= flags
+	erro.SetExtra(&meta)
+}
+
+// SetErrorField adds a custom field to erro, keeping flags and fields set before.
+func SetErrorField(erro esg.ErrorTypeWriteable, key string, value interface{}) {
+	meta := errorMetaOf(erro)
+	fields := make(map[string]interface{}, len(meta.Fields)+1)
+	for k, v := range meta.Fields {
+		fields[k] = v
+	}
+	fields[key] = value
+	meta.Fields = fields
+	erro.SetExtra(&meta)
+}
+
 func ErrModNoNeedToLog(erro esg.ErrorTypeWriteable) {
-	erro.SetExtra(&notWorthLogging)
+	SetErrorFlags(erro, ErrFlagNoNeedToLog)
 }
 
 func ErrModPrintAsInfo(erro esg.ErrorTypeWriteable) {
-	erro.SetExtra(&printErrAsInfo)
+	SetErrorFlags(erro, ErrFlagPrintAsInfo)
+}
+
+func ErrModRetryable(erro esg.ErrorTypeWriteable) {
+	SetErrorFlags(erro, ErrFlagRetryable)
+}
+
+func ErrModExposeDetails(erro esg.ErrorTypeWriteable) {
+	SetErrorFlags(erro, ErrFlagExposeDetails)
+}
+
+// IsRetryableError reports whether any ErrorType in the chain of err has ErrFlagRetryable.
+func IsRetryableError(err error) bool {
+	for i := 0; err != nil && i < maxErrorStackDepth; i++ {
+		if erro, ok := err.(ErrorType); ok && HasErrorFlag(erro, ErrFlagRetryable) {
+			return true
+		}
+		err = errors.Unwrap(err)
+	}
+	return false
 }
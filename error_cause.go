@@ -0,0 +1,78 @@
+package u
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// errorStack is the call stack where an error is constructed.
+// Error types keep a pointer to it, so that they remain comparable.
+type errorStack []uintptr
+
+// maxErrorStackDepth limits frames captured by constructors of error types.
+const maxErrorStackDepth = 32
+
+// callers captures the stack of the caller of an error constructor.
+func callers() *errorStack {
+	pcs := make([]uintptr, maxErrorStackDepth)
+	// Skip runtime.Callers, callers and the constructor.
+	n := runtime.Callers(3, pcs)
+	stack := errorStack(pcs[:n])
+	return &stack
+}
+
+// String formats frames like panics do.
+func (s *errorStack) String() string {
+	if s == nil || len(*s) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	frames := runtime.CallersFrames(*s)
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
+// causeOf returns err if it is an error. Causes of other types, e.g. strings, are only kept in messages.
+func causeOf(err interface{}) error {
+	cause, _ := err.(error)
+	return cause
+}
+
+// StackTracer is implemented by error types of this package.
+type StackTracer interface {
+	// StackTrace returns the call stack where the error is constructed.
+	StackTrace() string
+}
+
+// ErrorChain returns err and its causes, from the outermost, by errors.Unwrap.
+// Each entry is the type and message of an error, e.g. "u.DBQueryError: sql: no rows in result set".
+func ErrorChain(err error) []string {
+	var chain []string
+	for err != nil && len(chain) < maxErrorStackDepth {
+		chain = append(chain, fmt.Sprintf("%T: %v", err, err))
+		err = errors.Unwrap(err)
+	}
+	return chain
+}
+
+// ErrorStackTrace returns the stack of the innermost error in the chain of err which has one.
+func ErrorStackTrace(err error) string {
+	var stack string
+	for i := 0; err != nil && i < maxErrorStackDepth; i++ {
+		if st, ok := err.(StackTracer); ok {
+			if s := st.StackTrace(); s != "" {
+				stack = s
+			}
+		}
+		err = errors.Unwrap(err)
+	}
+	return stack
+}
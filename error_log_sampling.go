@@ -0,0 +1,170 @@
+package u
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrorLogSampling limits logs written by respondError for each ErrorCode().
+// In every Interval, the First errors of a code are logged, then 1 in Thereafter.
+// When an interval ends, the number of suppressed logs of the code is logged,
+// with the next error of the code or by a background flush at most one Interval later, whichever comes first.
+// Zero Interval disables sampling.
+type ErrorLogSampling struct {
+	Interval   time.Duration
+	First      int
+	Thereafter int
+
+	// PerCode overrides sampling of some codes. Key is ErrorCode() formatted with %v.
+	PerCode map[string]ErrorLogSampling
+}
+
+type errorLogCounter struct {
+	windowStart time.Time
+	count       int
+	suppressed  int
+}
+
+var (
+	errorLogSampling      ErrorLogSampling
+	errorLogCounters      = map[string]*errorLogCounter{}
+	errorLogFlushStop     chan struct{}
+	errorLogSamplingMutex sync.Mutex
+)
+
+// SetErrorLogSampling replaces sampling of respondError logs. Counters are reset.
+// Example, log the first 10 errors of each code per second, then 1 in 100:
+//
+//	SetErrorLogSampling(ErrorLogSampling{Interval: time.Second, First: 10, Thereafter: 100})
+func SetErrorLogSampling(sampling ErrorLogSampling) {
+	errorLogSamplingMutex.Lock()
+	defer errorLogSamplingMutex.Unlock()
+	errorLogSampling = sampling
+	errorLogCounters = map[string]*errorLogCounter{}
+
+	if errorLogFlushStop != nil {
+		close(errorLogFlushStop)
+		errorLogFlushStop = nil
+	}
+	if d := sampling.minInterval(); d > 0 {
+		errorLogFlushStop = make(chan struct{})
+		go flushErrorLogsEvery(d, errorLogFlushStop)
+	}
+}
+
+// samplingOf returns the sampling of key, which is PerCode[key] if any.
+func (s ErrorLogSampling) samplingOf(key string) ErrorLogSampling {
+	if p, ok := s.PerCode[key]; ok {
+		return p
+	}
+	return s
+}
+
+// minInterval returns the shortest positive Interval of s and PerCode. Zero if none.
+func (s ErrorLogSampling) minInterval() time.Duration {
+	d := s.Interval
+	for _, p := range s.PerCode {
+		if p.Interval > 0 && (d <= 0 || p.Interval < d) {
+			d = p.Interval
+		}
+	}
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// flushErrorLogsEvery logs summaries of ended intervals until stop is closed,
+// so they are not held back when no more errors of the code arrive.
+func flushErrorLogsEvery(d time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			flushErrorLogCounters(now)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// flushErrorLogCounters logs the number of suppressed logs of codes whose interval ended, and drops their counters.
+func flushErrorLogCounters(now time.Time) {
+	type summary struct {
+		code       string
+		suppressed int
+		interval   time.Duration
+	}
+	var summaries []summary
+
+	errorLogSamplingMutex.Lock()
+	for key, c := range errorLogCounters {
+		interval := errorLogSampling.samplingOf(key).Interval
+		if now.Sub(c.windowStart) < interval {
+			continue
+		}
+		if c.suppressed > 0 {
+			summaries = append(summaries, summary{key, c.suppressed, interval})
+		}
+		delete(errorLogCounters, key)
+	}
+	errorLogSamplingMutex.Unlock()
+
+	for _, s := range summaries {
+		logSuppressedErrors(s.code, s.suppressed, s.interval)
+	}
+}
+
+// sampleErrorLog returns true if the error of code should be logged.
+// The number of logs suppressed in the last interval of code is logged if the interval just ended.
+func sampleErrorLog(code interface{}, now time.Time) bool {
+	key := fmt.Sprintf("%v", code)
+	log, suppressed, interval := countErrorLog(key, now)
+	if suppressed > 0 {
+		logSuppressedErrors(key, suppressed, interval)
+	}
+	return log
+}
+
+func countErrorLog(key string, now time.Time) (log bool, suppressed int, interval time.Duration) {
+	errorLogSamplingMutex.Lock()
+	defer errorLogSamplingMutex.Unlock()
+
+	sampling := errorLogSampling.samplingOf(key)
+	if sampling.Interval <= 0 {
+		return true, 0, 0
+	}
+	interval = sampling.Interval
+
+	c, ok := errorLogCounters[key]
+	if !ok {
+		c = &errorLogCounter{windowStart: now}
+		errorLogCounters[key] = c
+	}
+	if now.Sub(c.windowStart) >= sampling.Interval {
+		suppressed = c.suppressed
+		*c = errorLogCounter{windowStart: now}
+	}
+
+	c.count++
+	if c.count <= sampling.First {
+		return true, suppressed, interval
+	}
+	if sampling.Thereafter > 0 && (c.count-sampling.First)%sampling.Thereafter == 0 {
+		return true, suppressed, interval
+	}
+	c.suppressed++
+	return false, suppressed, interval
+}
+
+func logSuppressedErrors(code string, suppressed int, interval time.Duration) {
+	SubsystemLogger(LogSubsystemGin).Warn("error logs suppressed by sampling",
+		zap.String("code", code),
+		zap.Int("suppressed", suppressed),
+		zap.String("interval", interval.String()),
+	)
+}
@@ -0,0 +1,249 @@
+package u
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+)
+
+// ErrorDefinition describes an error code for API docs, i18n and gRPC.
+type ErrorDefinition struct {
+	// Code equals ErrorCode() of the error type.
+	Code string
+	// StatusCode is the HTTP response status code.
+	StatusCode int
+	// GRPCCode is the status code returned by gRPC services.
+	GRPCCode codes.Code
+	// Messages are templates of Desc by locale, e.g. "en", "zh-CN".
+	// Templates are parsed by text/template with ErrorMessageData.
+	Messages map[string]string
+
+	templates map[string]*template.Template
+	locales   []string // sorted keys of templates
+}
+
+// ErrorMessageData is the data of message templates.
+type ErrorMessageData struct {
+	Code  string
+	Error string // Error() of the error
+	TID   string
+}
+
+// MarshalJSON writes GRPCCode by its name, e.g. "NotFound".
+func (d ErrorDefinition) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Code       string            `json:"code"`
+		StatusCode int               `json:"status"`
+		GRPCCode   string            `json:"grpc_code"`
+		Messages   map[string]string `json:"messages,omitempty"`
+	}{d.Code, d.StatusCode, d.GRPCCode.String(), d.Messages})
+}
+
+var (
+	errorRegistry      = map[string]*ErrorDefinition{}
+	errorRegistryMutex sync.RWMutex
+)
+
+// RegisterError adds or replaces the definition of def.Code. It panics if any message template is invalid.
+func RegisterError(def ErrorDefinition) {
+	def.templates = make(map[string]*template.Template, len(def.Messages))
+	messages := make(map[string]string, len(def.Messages))
+	for locale, text := range def.Messages {
+		def.templates[strings.ToLower(locale)] = template.Must(template.New(def.Code + "/" + locale).Parse(text))
+		messages[locale] = text
+	}
+	def.Messages = messages
+	def.locales = make([]string, 0, len(def.templates))
+	for locale := range def.templates {
+		def.locales = append(def.locales, locale)
+	}
+	sort.Strings(def.locales)
+
+	errorRegistryMutex.Lock()
+	defer errorRegistryMutex.Unlock()
+	errorRegistry[def.Code] = &def
+}
+
+// SetErrorMessage adds or replaces the message template of a registered code in locale.
+// It returns false if code is not registered.
+func SetErrorMessage(code, locale, text string) bool {
+	def, ok := LookupError(code)
+	if !ok {
+		return false
+	}
+	messages := make(map[string]string, len(def.Messages)+1)
+	for l, t := range def.Messages {
+		messages[l] = t
+	}
+	messages[locale] = text
+	def.Messages = messages
+	RegisterError(def)
+	return true
+}
+
+// LookupError returns the definition of code, which is usually ErrorCode() of an ErrorType.
+func LookupError(code interface{}) (ErrorDefinition, bool) {
+	errorRegistryMutex.RLock()
+	defer errorRegistryMutex.RUnlock()
+	def, ok := errorRegistry[fmt.Sprint(code)]
+	if !ok {
+		return ErrorDefinition{}, false
+	}
+	return *def, true
+}
+
+// ErrorCatalogue returns all registered definitions ordered by code.
+func ErrorCatalogue() []ErrorDefinition {
+	errorRegistryMutex.RLock()
+	defer errorRegistryMutex.RUnlock()
+	defs := make([]ErrorDefinition, 0, len(errorRegistry))
+	for _, def := range errorRegistry {
+		defs = append(defs, *def)
+	}
+	sort.Slice(defs, func(i, j int) bool {
+		return defs[i].Code < defs[j].Code
+	})
+	return defs
+}
+
+// RegisterErrorCatalogueRoutes registers a route for exporting the error catalogue.
+//
+//	GET /errors  responds {"error": null, "errors": [{"code": "Conflict", "status": 409, "grpc_code": "AlreadyExists"}]}
+func RegisterErrorCatalogueRoutes(routes gin.IRoutes) {
+	routes.GET("/errors", ErrorCatalogueHandler)
+}
+
+func ErrorCatalogueHandler(c *gin.Context) {
+	h := NewGinHelper(c)
+	h.RespondKV200("errors", ErrorCatalogue(), nil)
+}
+
+// LocalizedDesc returns Desc of erro in the language preferred by acceptLanguage, the value of Accept-Language header.
+// It returns erro.Error() if the code is not registered or has no message in any acceptable language.
+func LocalizedDesc(erro ErrorType, acceptLanguage string, tid string) string {
+	if acceptLanguage == "" {
+		return erro.Error()
+	}
+	def, ok := LookupError(erro.ErrorCode())
+	if !ok || len(def.templates) == 0 {
+		return erro.Error()
+	}
+	tmpl := def.templateFor(acceptLanguage)
+	if tmpl == nil {
+		return erro.Error()
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, ErrorMessageData{Code: def.Code, Error: erro.Error(), TID: tid}); err != nil {
+		Logger.Error("Failed to execute message template",
+			zap.String("tid", tid),
+			zap.String("code", def.Code),
+			zap.Error(err),
+		)
+		return erro.Error()
+	}
+	return buf.String()
+}
+
+// templateFor matches languages of acceptLanguage in order of quality, first exactly, then by primary subtag.
+// If several locales share the primary subtag, e.g. "zh-CN" and "zh-TW" for "zh", the first one in alphabetical order is used.
+func (d *ErrorDefinition) templateFor(acceptLanguage string) *template.Template {
+	for _, lang := range parseAcceptLanguage(acceptLanguage) {
+		if lang == "*" {
+			continue
+		}
+		if t, ok := d.templates[lang]; ok {
+			return t
+		}
+		primary := primaryLanguage(lang)
+		if t, ok := d.templates[primary]; ok {
+			return t
+		}
+		for _, locale := range d.locales {
+			if primaryLanguage(locale) == primary {
+				return d.templates[locale]
+			}
+		}
+	}
+	return nil
+}
+
+// parseAcceptLanguage returns lower case languages ordered by quality, e.g. "zh-CN,en;q=0.8" returns ["zh-cn", "en"].
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		lang string
+		q    float64
+	}
+	var langs []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		lang := strings.ToLower(strings.TrimSpace(fields[0]))
+		if lang == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			langs = append(langs, weighted{lang, q})
+		}
+	}
+	sort.SliceStable(langs, func(i, j int) bool {
+		return langs[i].q > langs[j].q
+	})
+	result := make([]string, len(langs))
+	for i, l := range langs {
+		result[i] = l.lang
+	}
+	return result
+}
+
+func primaryLanguage(lang string) string {
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		return lang[:i]
+	}
+	return lang
+}
+
+// Error types of this package. AnyError is not registered since its code is given by caller.
+// Code and StatusCode are taken from the error types, so they cannot drift apart.
+func init() {
+	for _, e := range []struct {
+		erro     ErrorType
+		grpcCode codes.Code
+	}{
+		{ErrCantStartConsumer(nil), codes.Internal},
+		{ErrConflict(nil), codes.AlreadyExists},
+		{ErrConsumerError(nil), codes.Internal},
+		{ErrConsumerNotFound(nil), codes.NotFound},
+		{ErrDBExecutionError(nil), codes.Internal},
+		{ErrDBQueryError(nil), codes.Internal},
+		{ErrFailedToMarshalJSON(nil), codes.Internal},
+		{ErrFailedToReadRequestBody(nil), codes.Internal},
+		{ErrFailedToUnmarshalJSON(nil), codes.InvalidArgument},
+		{ErrGRPCDialErr(nil, nil), codes.Unavailable},
+		{ErrInternalError(nil), codes.Internal},
+		{ErrInvalidJWT(nil), codes.Unauthenticated},
+		{ErrInvalidLogLevel(nil), codes.InvalidArgument},
+		{ErrMongoQueryErr(nil), codes.Internal},
+		{ErrMongoWriteErr(nil), codes.Internal},
+		{ErrParamBindingErr(nil), codes.InvalidArgument},
+		{ErrShortUUIDLenConstraint(), codes.Internal},
+	} {
+		RegisterError(ErrorDefinition{Code: fmt.Sprint(e.erro.ErrorCode()), StatusCode: e.erro.StatusCode(), GRPCCode: e.grpcCode})
+	}
+}
@@ -6,36 +6,28 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"net/http"
 	"reflect"
+	"sort"
 	"strings"
-	"unsafe"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 const TraceIDKey = "TID"
 
+// TraceIDHeader is the request header from which GinMiddleware and GinLogger take the trace ID of the caller.
+// It takes precedence over W3C and B3 headers. The trace ID is echoed back in the response header of the same name.
+// Set it to "" to disable both.
+var TraceIDHeader = "X-Trace-Id"
+
+// traceIDForGinCreateIfNil returns the trace ID of the CTX of c. The CTX is created if not.
 func traceIDForGinCreateIfNil(c *gin.Context) (traceID string) {
 	if c == nil {
 		return "gin_Context_must_not_be_nil!"
 	}
-	value, ok := c.Get(TraceIDKey)
-	needCreate := false
-	if ok {
-		traceID, ok = value.(string)
-		if !ok {
-			needCreate = true
-		}
-	} else {
-		needCreate = true
-	}
-
-	if needCreate {
-		traceID = UUID12()
-		c.Set(TraceIDKey, traceID)
-	}
-	return traceID
+	return getCTX(c).TraceID()
 }
 
 func traceIDFromGin(c *gin.Context) (traceID string) {
@@ -58,9 +50,16 @@ type GinHelper struct {
 //	return g
 //}
 
+// GinMiddleware injects CTX and recovers panics. Prometheus metrics are recorded for each request, see MetricsHandler.
+// A server span is recorded for each request if TracerProvider is set.
 func GinMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		begin := time.Now()
+		defer observeGinRequest(c, begin)
 		injectCTX(c)
+		captureRequestBody(c)
+		endSpan := ginTracing(c)
+		defer endSpan()
 		defer handlePanic(c)
 		c.Next()
 	}
@@ -71,14 +70,46 @@ func injectCTX(c *gin.Context) *CTX {
 		return nil
 	}
 
+	// Trace context of the caller is accepted in TraceIDHeader, W3C, B3 or legacy tid headers.
 	ctx := &CTX{
-		traceID: UUID12(),
+		parent: c.Request.Context(),
+	}
+	if tc, ok := TraceContextFromHeader(c.Request.Header); ok {
+		ctx.setTraceContext(tc)
+	}
+	if traceID := traceIDFromRequestHeader(c); traceID != "" {
+		if sameTraceID(traceID, ctx.traceID) {
+			ctx.traceID = traceID
+		} else {
+			// Parent span, tracestate and sampling of traceparent or b3 belong to another trace.
+			ctx.setTraceContext(TraceContext{TraceID: traceID})
+		}
+	}
+	if ctx.traceID == "" {
+		ctx.traceID = NewTraceID()
 	}
 	c.Set("ctx", ctx)
+	// Same trace ID for CTX and GinLogger.
+	c.Set(TraceIDKey, ctx.traceID)
+	if TraceIDHeader != "" {
+		c.Header(TraceIDHeader, ctx.traceID)
+	}
 
 	return ctx
 }
 
+// traceIDFromRequestHeader returns "" if the value of TraceIDHeader is absent or not suitable for logs.
+func traceIDFromRequestHeader(c *gin.Context) string {
+	if TraceIDHeader == "" {
+		return ""
+	}
+	traceID := c.GetHeader(TraceIDHeader)
+	if !isLoggableTraceID(traceID) {
+		return ""
+	}
+	return traceID
+}
+
 func getCTX(c *gin.Context) *CTX {
 	if v, ok := c.Get("ctx"); ok {
 		if ctx, ok := v.(*CTX); ok {
@@ -105,6 +136,10 @@ type ErrorPayload struct {
 	Code interface{} `json:"code,omitempty"`
 	Desc string      `json:"desc"`
 	TID  string      `json:"tid,omitempty"`
+	// Retryable is true if ErrFlagRetryable is set.
+	Retryable bool `json:"retryable,omitempty"`
+	// Details has custom fields and causes if ErrFlagExposeDetails is set.
+	Details KV `json:"details,omitempty"`
 }
 
 type KV = map[string]interface{}
@@ -220,64 +255,84 @@ func respondError(gc *gin.Context, erro ErrorType) {
 	body := commonResponseBody()
 	payload := ErrorPayload{
 		Code: erro.ErrorCode(),
-		Desc: erro.Error(),
+		TID:  traceIDForGinCreateIfNil(gc),
+	}
+	// Desc is localized by message templates of the error registry. See RegisterError.
+	payload.Desc = LocalizedDesc(erro, gc.GetHeader("Accept-Language"), payload.TID)
+	meta := ErrorMetaOf(erro)
+	payload.Retryable = meta.Flags&ErrFlagRetryable != 0
+	if meta.Flags&ErrFlagExposeDetails != 0 {
+		payload.Details = KV{}
+		for k, v := range meta.Fields {
+			payload.Details[k] = v
+		}
+		if causes := ErrorChain(erro); len(causes) > 1 {
+			payload.Details["causes"] = causes[1:]
+		}
 	}
-
-	payload.TID = traceIDForGinCreateIfNil(gc)
 	body[errorKey] = payload
 
-	if gin.IsDebugging() || (erro.Extra() != &notWorthLogging && erro.StatusCode() >= 500) {
-		// get raw string of http request using reflect.
-		requestLog := requestAsText(gc.Request)
+	observeErrorResponse(erro)
 
-		log := fmt.Sprintf("tid=%v; %scode=%v; error=%v; status=%v", payload.TID, requestLog, erro.ErrorCode(), erro.Error(), erro.StatusCode())
+	// Sampling applies before dumping the request, which is the expensive part. See SetErrorLogSampling.
+	if (gin.IsDebugging() || (meta.Flags&ErrFlagNoNeedToLog == 0 && erro.StatusCode() >= 500)) && sampleErrorLog(erro.ErrorCode(), time.Now()) {
+		requestLog := requestAsText(gc)
+
+		fields := []zap.Field{
+			zap.String("tid", payload.TID),
+			zap.Any("code", erro.ErrorCode()),
+			zap.Int("status", erro.StatusCode()),
+			zap.String("request", requestLog),
+		}
+		for k, v := range meta.Fields {
+			fields = append(fields, zap.Any(k, v))
+		}
+		// Causes and stack are only logged. Clients see Desc only, unless ErrFlagExposeDetails is set.
+		if causes := ErrorChain(erro); len(causes) > 1 {
+			fields = append(fields, zap.Strings("causes", causes))
+		}
+		if stack := ErrorStackTrace(erro); stack != "" {
+			fields = append(fields, zap.String("stack", stack))
+		}
 
-		if erro.Extra() == &printErrAsInfo {
-			Info(log)
+		if meta.Flags&ErrFlagPrintAsInfo != 0 {
+			SubsystemLogger(LogSubsystemGin).Info(erro.Error(), fields...)
 		} else {
-			Error(log)
+			SubsystemLogger(LogSubsystemGin).Error(erro.Error(), fields...)
 		}
 	}
 
 	respondJSON(gc, erro.StatusCode(), body)
 }
 
+// MaxLengthOfRequestDump is the maximum number of bytes of request body in logs.
 var MaxLengthOfRequestDump = 4 * 1024
 
-func requestAsText(request *http.Request) (requestLog string) {
-	if request.ContentLength > 0 && request.Body != nil {
-		// Possible to fail with future go version.
-		v := reflect.ValueOf(request.Body).Elem()
-		v = v.FieldByName("src").Elem().Elem()
-		v = v.FieldByName("R").Elem().Elem()
-		lv := v.FieldByName("w") // get length of package
-		lv = reflect.NewAt(lv.Type(), unsafe.Pointer(lv.UnsafeAddr())).Elem()
-		length := lv.Interface().(int)
-		v = v.FieldByName("buf")
-		v = reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem()
-		buf, ok := v.Interface().([]byte)
-
-		if length > MaxLengthOfRequestDump {
-			length = MaxLengthOfRequestDump
+// requestAsText dumps request line, headers and body captured by GinMiddleware, GinLogger or RequestBodyCapture.
+func requestAsText(c *gin.Context) (requestLog string) {
+	request := c.Request
+	// assemble headers. Sensitive data is masked by Redaction.
+	headers := Redaction.redactHeader(request.Header)
+	sort.Strings(headers)
+
+	var body string
+	if dump := capturedRequestBody(c); dump != nil {
+		body = Redaction.RedactJSON(dump.body)
+		if dump.truncated {
+			body += "...(truncated)"
 		}
-
-		if ok {
-			requestLog = fmt.Sprintf("request:\n%s\n---EOR---\n", string(buf[:length]))
-		}
-	} else {
-		// assemble headers
-		headers := make([]string, 0, len(request.Header))
-		for k, vs := range request.Header {
-			for _, v := range vs {
-				headers = append(headers, k+": "+v)
-			}
+		if dump.err != nil {
+			body += fmt.Sprintf("...(failed to read body: %v)", dump.err)
 		}
-		requestLog = fmt.Sprintf(`request:
+	}
+
+	requestLog = fmt.Sprintf(`request:
 %s %s
 %v
+
+%s
 ---EOR---
-`, request.Method, request.RequestURI, strings.Join(headers, "\n"))
-	}
+`, request.Method, request.RequestURI, strings.Join(headers, "\n"), body)
 
 	return
 }
@@ -402,9 +457,9 @@ func handlePanic(c *gin.Context) {
 }
 
 // CreateGRPCContext create a context.Context with header "tid".
+// Deadline and cancellation of the incoming request are kept.
 func (r *GinHelper) CreateGRPCContext() context.Context {
-	context := context.Background()
-	return getCTX(r.Context).FillGRPCContext(context)
+	return getCTX(r.Context).CreateGRPCContext()
 }
 
 func GetJWTClaims(c *gin.Context, claimsPointer any) {
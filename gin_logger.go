@@ -4,20 +4,37 @@
 package u
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
 	"os"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mattn/go-isatty"
+	"go.uber.org/zap"
 )
 
 type consoleColorModeValue int
 
+// SlowGinRequestLatencyThreshold is the default of LoggerConfig.SlowThreshold.
 var SlowGinRequestLatencyThreshold = time.Second
 
+// LogFormat is the output format of LoggerWithConfig when Formatter is nil.
+type LogFormat int
+
+const (
+	// LogFormatText is the format of gin.
+	LogFormatText LogFormat = iota
+	// LogFormatJSON writes a JSON object per line.
+	LogFormatJSON
+)
+
 const (
 	autoColor consoleColorModeValue = iota
 	disableColor
@@ -49,6 +66,88 @@ type LoggerConfig struct {
 	// SkipPaths is an url path array which logs are not written.
 	// Optional.
 	SkipPaths []string
+
+	// SkipRules are rules of requests which logs are not written.
+	// Optional.
+	SkipRules []SkipRule
+
+	// UseZap writes logs to Logger with structured fields
+	// tid, status, latency_ms, client_ip, method, path, error and body_size.
+	// Formatter and Output are ignored if true.
+	// Optional.
+	UseZap bool
+
+	// Format is used if Formatter is nil.
+	// Optional. Default value is LogFormatText.
+	Format LogFormat
+
+	// AccessLog logs requests even if gin is not in debug mode and requests are not slow.
+	// Otherwise, only slow requests are logged in release mode.
+	// Optional.
+	AccessLog bool
+
+	// SampleRate is the fraction of requests logged in access-log mode, in (0, 1].
+	// Slow requests and responses with status >= 500 are always logged.
+	// Optional. Default value 0 means 1.
+	SampleRate float64
+
+	// CaptureResponseBody logs at most MaxResponseBodySize bytes of response body.
+	// Optional.
+	CaptureResponseBody bool
+
+	// MaxResponseBodySize limits the captured response body.
+	// Optional. Default value is MaxLengthOfRequestDump.
+	MaxResponseBodySize int
+
+	// SlowThreshold is the latency above which requests are slow.
+	// Optional. Default value is SlowGinRequestLatencyThreshold.
+	SlowThreshold time.Duration
+
+	// SlowThresholds overrides SlowThreshold by route template, e.g. "/users/:id".
+	// Optional.
+	SlowThresholds map[string]time.Duration
+}
+
+// SkipRule matches requests by route and response status.
+// Route, Prefix and Pattern are alternatives. A rule without any of them matches all routes.
+type SkipRule struct {
+	// Route is a route template, e.g. "/users/:id", compared with c.FullPath().
+	Route string
+	// Prefix matches route templates and paths which start with it, e.g. "/debug/".
+	Prefix string
+	// Pattern is a glob pattern of path.Match, e.g. "/internal/*/health", matched against route templates and paths.
+	Pattern string
+	// StatusClasses restricts the rule to responses of the classes, e.g. 2 for 2xx. Empty means any status.
+	StatusClasses []int
+}
+
+// matches reports whether the rule matches a request of route template route, path p and response status.
+func (r *SkipRule) matches(route, p string, status int) bool {
+	switch {
+	case r.Route != "":
+		if route != r.Route {
+			return false
+		}
+	case r.Prefix != "":
+		if !(route != "" && strings.HasPrefix(route, r.Prefix)) && !strings.HasPrefix(p, r.Prefix) {
+			return false
+		}
+	case r.Pattern != "":
+		if ok, _ := path.Match(r.Pattern, route); !ok {
+			if ok, _ := path.Match(r.Pattern, p); !ok {
+				return false
+			}
+		}
+	}
+	if len(r.StatusClasses) == 0 {
+		return true
+	}
+	for _, class := range r.StatusClasses {
+		if status/100 == class {
+			return true
+		}
+	}
+	return false
 }
 
 // LogFormatter gives the signature of the formatter function passed to LoggerWithFormatter
@@ -68,8 +167,13 @@ type LogFormatterParams struct {
 	ClientIP string
 	// Method is the HTTP method given to the request.
 	Method string
-	// Path is a path the client requests.
+	// Route is the route template, e.g. "/users/:id". Empty if no route matches.
+	Route string
+	// Path is the concrete path the client requests, e.g. "/users/42".
+	// Path no longer contains the query. Custom formatters which need it should use RequestURI or RawQuery.
 	Path string
+	// RawQuery is the query of the request without "?".
+	RawQuery string
 	// ErrorMessage is set if error has occurred in processing the request.
 	ErrorMessage string
 	// isTerm shows whether it does gin's output descriptor refers to a terminal.
@@ -78,10 +182,29 @@ type LogFormatterParams struct {
 	BodySize int
 	// Keys are the keys set on the request's context.
 	Keys map[string]interface{}
+	// Slow is true if Latency exceeds the slow threshold of the route.
+	Slow bool
+	// ResponseBody is the head of response body if LoggerConfig.CaptureResponseBody is true.
+	ResponseBody string
+	// ResponseBodyTruncated is true if ResponseBody is not the whole response body.
+	ResponseBodyTruncated bool
 
 	traceID string
 }
 
+// TraceID is the trace ID of the request.
+func (p *LogFormatterParams) TraceID() string {
+	return p.traceID
+}
+
+// RequestURI is Path with the query, e.g. "/users/42?fields=name", which is what Path used to be.
+func (p *LogFormatterParams) RequestURI() string {
+	if p.RawQuery == "" {
+		return p.Path
+	}
+	return p.Path + "?" + p.RawQuery
+}
+
 // StatusCodeColor is the ANSI color for appropriately logging http status code to a terminal.
 func (p *LogFormatterParams) StatusCodeColor() string {
 	code := p.StatusCode
@@ -147,11 +270,19 @@ var defaultLogFormatter = func(param LogFormatterParams) string {
 	}
 
 	var slowTag string
-	if param.Latency > SlowGinRequestLatencyThreshold {
+	if param.Slow {
 		slowTag = fmt.Sprintf("%s[SLOW]%s", magenta, reset)
 	}
 
-	return fmt.Sprintf("%v [GIN][%s]%s |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+	var responseBody string
+	if param.ResponseBody != "" {
+		responseBody = fmt.Sprintf(" | %s", param.ResponseBody)
+		if param.ResponseBodyTruncated {
+			responseBody += "...(truncated)"
+		}
+	}
+
+	return fmt.Sprintf("%v [GIN][%s]%s |%s %3d %s| %13v | %15s |%s %-7s %s %#v%s\n%s",
 		param.TimeStamp.Format("2006/01/02 15:04:05"),
 		param.traceID,
 		slowTag,
@@ -159,11 +290,68 @@ var defaultLogFormatter = func(param LogFormatterParams) string {
 		param.Latency,
 		param.ClientIP,
 		methodColor, param.Method, resetColor,
-		param.Path,
+		param.RequestURI(),
+		responseBody,
 		param.ErrorMessage,
 	)
 }
 
+// jsonLogFormatter writes fields of zapLog as a JSON object per line.
+var jsonLogFormatter = func(param LogFormatterParams) string {
+	entry := map[string]interface{}{
+		"time":       param.TimeStamp.Format(time.RFC3339Nano),
+		"tid":        param.traceID,
+		"status":     param.StatusCode,
+		"latency_ms": float64(param.Latency.Nanoseconds()) / 1e6,
+		"client_ip":  param.ClientIP,
+		"method":     param.Method,
+		"route":      param.Route,
+		"path":       param.Path,
+		"body_size":  param.BodySize,
+	}
+	if param.Slow {
+		entry["slow"] = true
+	}
+	if param.ErrorMessage != "" {
+		entry["error"] = param.ErrorMessage
+	}
+	if param.ResponseBody != "" {
+		entry["response"] = param.ResponseBody
+		entry["response_truncated"] = param.ResponseBodyTruncated
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Sprintf("{\"error\":%q}\n", err.Error())
+	}
+	return string(line) + "\n"
+}
+
+// zapLog writes param to Logger. Slow requests are logged as warnings.
+func zapLog(param LogFormatterParams) {
+	fields := []zap.Field{
+		zap.String("tid", param.traceID),
+		zap.Int("status", param.StatusCode),
+		zap.Float64("latency_ms", float64(param.Latency.Nanoseconds())/1e6),
+		zap.String("client_ip", param.ClientIP),
+		zap.String("method", param.Method),
+		zap.String("route", param.Route),
+		zap.String("path", param.Path),
+		zap.Int("body_size", param.BodySize),
+	}
+	if param.ErrorMessage != "" {
+		fields = append(fields, zap.String("error", param.ErrorMessage))
+	}
+	if param.ResponseBody != "" {
+		fields = append(fields, zap.String("response", param.ResponseBody), zap.Bool("response_truncated", param.ResponseBodyTruncated))
+	}
+	logger := SubsystemLogger(LogSubsystemGin)
+	if param.Slow {
+		logger.Warn("GIN [SLOW]", fields...)
+		return
+	}
+	logger.Info("GIN", fields...)
+}
+
 // DisableConsoleColor disables color output in the console.
 func DisableConsoleColor() {
 	consoleColorMode = disableColor
@@ -196,6 +384,13 @@ func GinLogger() gin.HandlerFunc {
 	return LoggerWithConfig(LoggerConfig{})
 }
 
+// GinZapLogger instances a Logger middleware that will write the logs to Logger with structured fields.
+func GinZapLogger() gin.HandlerFunc {
+	return LoggerWithConfig(LoggerConfig{
+		UseZap: true,
+	})
+}
+
 // LoggerWithFormatter instance a Logger middleware with the specified log format function.
 func LoggerWithFormatter(f LogFormatter) gin.HandlerFunc {
 	return LoggerWithConfig(LoggerConfig{
@@ -217,6 +412,19 @@ func LoggerWithConfig(conf LoggerConfig) gin.HandlerFunc {
 	formatter := conf.Formatter
 	if formatter == nil {
 		formatter = defaultLogFormatter
+		if conf.Format == LogFormatJSON {
+			formatter = jsonLogFormatter
+		}
+	}
+
+	slowThreshold := conf.SlowThreshold
+	if slowThreshold <= 0 {
+		slowThreshold = SlowGinRequestLatencyThreshold
+	}
+
+	maxResponseBodySize := conf.MaxResponseBodySize
+	if maxResponseBodySize <= 0 {
+		maxResponseBodySize = MaxLengthOfRequestDump
 	}
 
 	out := conf.Output
@@ -249,18 +457,24 @@ func LoggerWithConfig(conf LoggerConfig) gin.HandlerFunc {
 		path := c.Request.URL.Path
 		raw := c.Request.URL.RawQuery
 
-		traceID := traceIDForGinCreateIfNil(c)
+		captureRequestBody(c)
 
 		if gin.IsDebugging() {
-			requestText := requestAsText(c.Request)
-			Debugf("RCV %s", requestText)
+			requestText := requestAsText(c)
+			SubsystemLogger(LogSubsystemGin).Debug("RCV", zap.String("tid", traceIDForGinCreateIfNil(c)), zap.String("request", requestText))
+		}
+
+		var responseBody *responseBodyWriter
+		if conf.CaptureResponseBody {
+			responseBody = &responseBodyWriter{ResponseWriter: c.Writer, limit: maxResponseBodySize}
+			c.Writer = responseBody
 		}
 
 		// Process request
 		c.Next()
 
 		// Log only when path is not being skipped
-		if _, ok := skip[path]; !ok {
+		if _, ok := skip[path]; !ok && !conf.skipped(c.FullPath(), path, c.Writer.Status()) {
 			param := LogFormatterParams{
 				Request: c.Request,
 				isTerm:  isTerm,
@@ -271,26 +485,88 @@ func LoggerWithConfig(conf LoggerConfig) gin.HandlerFunc {
 			param.TimeStamp = time.Now()
 			param.Latency = param.TimeStamp.Sub(start)
 
-			if !gin.IsDebugging() && param.Latency < SlowGinRequestLatencyThreshold {
+			param.Route = c.FullPath()
+			threshold := slowThreshold
+			if t, ok := conf.SlowThresholds[param.Route]; ok {
+				threshold = t
+			}
+			param.Slow = param.Latency > threshold
+			param.StatusCode = c.Writer.Status()
+
+			if !gin.IsDebugging() && !param.Slow && !conf.sampled(param.StatusCode) {
 				return
 			}
 
 			param.ClientIP = c.ClientIP()
 			param.Method = c.Request.Method
-			param.StatusCode = c.Writer.Status()
 			param.ErrorMessage = c.Errors.ByType(gin.ErrorTypePrivate).String()
 
 			param.BodySize = c.Writer.Size()
 
-			if raw != "" {
-				path = path + "?" + raw
+			if responseBody != nil {
+				param.ResponseBody = Redaction.RedactJSON(responseBody.body.Bytes())
+				param.ResponseBodyTruncated = responseBody.truncated
 			}
 
 			param.Path = path
+			param.RawQuery = raw
+
+			// Read after c.Next(), since GinMiddleware may replace the trace ID with the one of the server span.
+			param.traceID = traceIDForGinCreateIfNil(c)
 
-			param.traceID = traceID
+			if conf.UseZap {
+				zapLog(param)
+				return
+			}
 
 			fmt.Fprint(out, formatter(param))
 		}
 	}
 }
+
+// skipped reports whether any of SkipRules matches the request.
+func (conf *LoggerConfig) skipped(route, p string, status int) bool {
+	for i := range conf.SkipRules {
+		if conf.SkipRules[i].matches(route, p, status) {
+			return true
+		}
+	}
+	return false
+}
+
+// sampled reports whether a request which is not slow is logged in access-log mode.
+func (conf *LoggerConfig) sampled(status int) bool {
+	if !conf.AccessLog {
+		return false
+	}
+	if status >= http.StatusInternalServerError || conf.SampleRate <= 0 || conf.SampleRate >= 1 {
+		return true
+	}
+	return rand.Float64() < conf.SampleRate
+}
+
+// responseBodyWriter keeps at most limit bytes of response body.
+type responseBodyWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (w *responseBodyWriter) capture(b []byte) {
+	if room := w.limit - w.body.Len(); room < len(b) {
+		b = b[:room]
+		w.truncated = true
+	}
+	w.body.Write(b)
+}
+
+func (w *responseBodyWriter) Write(b []byte) (int, error) {
+	w.capture(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseBodyWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
@@ -46,12 +46,16 @@ func DialGRPC(host string, panicIfErrorOccurred bool) (*grpc.ClientConn, ErrorTy
 			PermitWithoutStream: true,
 		}),
 		grpc.WithChainUnaryInterceptor(grpc_middleware.ChainUnaryClient(
-			grpc_zap.UnaryClientInterceptor(Logger, GRPCClientZapLogOption()),
+			GRPCTracingUnaryClientInterceptor,
+			grpc_zap.UnaryClientInterceptor(SubsystemLogger(LogSubsystemGRPC), GRPCClientZapLogOption()),
 			grpc_prometheus.UnaryClientInterceptor,
+			GRPCErrorUnaryClientInterceptor,
 		)),
 		grpc.WithChainStreamInterceptor(grpc_middleware.ChainStreamClient(
-			grpc_zap.StreamClientInterceptor(Logger, GRPCClientZapLogOption()),
+			GRPCTracingStreamClientInterceptor,
+			grpc_zap.StreamClientInterceptor(SubsystemLogger(LogSubsystemGRPC), GRPCClientZapLogOption()),
 			grpc_prometheus.StreamClientInterceptor,
+			GRPCErrorStreamClientInterceptor,
 		)),
 	)
 
@@ -64,6 +68,7 @@ func DialGRPC(host string, panicIfErrorOccurred bool) (*grpc.ClientConn, ErrorTy
 		return nil, erro
 	}
 	Infof("Create connection to GRPC Server %s", host)
+	DefaultLifecycle.RegisterGRPCConn(conn)
 
 	return conn, nil
 }
@@ -0,0 +1,188 @@
+package u
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCErrorDomain is ErrorInfo.Domain of statuses converted from ErrorType.
+const GRPCErrorDomain = "github.com/simplefelix/u"
+
+// Keys of ErrorInfo.Metadata.
+const (
+	grpcErrorMetaTID        = "tid"
+	grpcErrorMetaHTTPStatus = "http_status"
+	grpcErrorMetaRetryable  = "retryable"
+)
+
+// GRPCCodeOf returns the gRPC code of erro, registered by RegisterError or mapped from its StatusCode.
+func GRPCCodeOf(erro ErrorType) codes.Code {
+	if def, ok := LookupError(erro.ErrorCode()); ok {
+		return def.GRPCCode
+	}
+	return grpcCodeFromHTTPStatus(erro.StatusCode())
+}
+
+// GRPCStatusFromError converts erro to a status with an ErrorInfo of ErrorCode, trace ID and HTTP status code in details.
+func GRPCStatusFromError(erro ErrorType, tid string) *status.Status {
+	s := status.New(GRPCCodeOf(erro), erro.Error())
+	info := &errdetails.ErrorInfo{
+		Reason: fmt.Sprint(erro.ErrorCode()),
+		Domain: GRPCErrorDomain,
+		Metadata: map[string]string{
+			grpcErrorMetaTID:        tid,
+			grpcErrorMetaHTTPStatus: strconv.Itoa(erro.StatusCode()),
+		},
+	}
+	if HasErrorFlag(erro, ErrFlagRetryable) {
+		info.Metadata[grpcErrorMetaRetryable] = "true"
+	}
+	if detailed, err := s.WithDetails(info); err == nil {
+		s = detailed
+	}
+	return s
+}
+
+// grpcErrorFromHandler converts err returned by a handler if it is or wraps an ErrorType. Statuses are kept.
+func grpcErrorFromHandler(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(interface{ GRPCStatus() *status.Status }); ok {
+		return err
+	}
+	var erro ErrorType
+	if !errors.As(err, &erro) {
+		return err
+	}
+	return GRPCStatusFromError(erro, TraceIDFromIncoming(ctx)).Err()
+}
+
+// recoverGRPCError converts a panicked ErrorType to a status error. Other panics are re-panicked.
+func recoverGRPCError(ctx context.Context, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	erro, ok := r.(ErrorType)
+	if !ok {
+		panic(r)
+	}
+	SubsystemLogger(LogSubsystemGRPC).Error("gRPC handler panicked",
+		zap.String("tid", TraceIDFromIncoming(ctx)),
+		zap.Any("code", erro.ErrorCode()),
+		zap.Error(erro),
+	)
+	*err = GRPCStatusFromError(erro, TraceIDFromIncoming(ctx)).Err()
+}
+
+// GRPCErrorUnaryServerInterceptor converts ErrorType returned or panicked by handlers to statuses. See GRPCStatusFromError.
+func GRPCErrorUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer recoverGRPCError(ctx, &err)
+	resp, err = handler(ctx, req)
+	return resp, grpcErrorFromHandler(ctx, err)
+}
+
+// GRPCErrorStreamServerInterceptor is the stream version of GRPCErrorUnaryServerInterceptor.
+func GRPCErrorStreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer recoverGRPCError(ss.Context(), &err)
+	return grpcErrorFromHandler(ss.Context(), handler(srv, ss))
+}
+
+// GRPCErrorUnaryClientInterceptor converts status errors to GRPCRemoteError, so that they can be passed to GinHelper.RespondError.
+// Used by DialGRPC.
+func GRPCErrorUnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return ErrorTypeFromGRPCError(invoker(ctx, method, req, reply, cc, opts...))
+}
+
+// GRPCErrorStreamClientInterceptor converts status errors of creating streams. Errors of RecvMsg and SendMsg are not converted.
+// Used by DialGRPC.
+func GRPCErrorStreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	s, err := streamer(ctx, desc, cc, method, opts...)
+	return s, ErrorTypeFromGRPCError(err)
+}
+
+// ErrorTypeFromGRPCError returns a GRPCRemoteError if err is a status error other than OK, or err itself otherwise.
+func ErrorTypeFromGRPCError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(ErrorType); ok {
+		return err
+	}
+	s, ok := status.FromError(err)
+	if !ok || s.Code() == codes.OK {
+		return err
+	}
+	return ErrGRPCRemoteError(s)
+}
+
+func grpcCodeFromHTTPStatus(statusCode int) codes.Code {
+	switch statusCode {
+	case http.StatusOK:
+		return codes.OK
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case 499:
+		return codes.Canceled
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	if statusCode >= 400 && statusCode < 500 {
+		return codes.FailedPrecondition
+	}
+	return codes.Internal
+}
+
+func httpStatusFromGRPCCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
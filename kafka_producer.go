@@ -0,0 +1,125 @@
+package u
+
+import (
+	"github.com/Shopify/sarama"
+	"go.uber.org/zap"
+)
+
+// KafkaTraceIDHeader is the record header key of trace ID. Same as the key in GRPC metadata.
+const KafkaTraceIDHeader = "tid"
+
+// AppendTraceIDToProducerMessage appends header "tid" to msg. Existing "tid" header will be replaced.
+func AppendTraceIDToProducerMessage(msg *sarama.ProducerMessage, traceID string) {
+	for i, h := range msg.Headers {
+		if string(h.Key) == KafkaTraceIDHeader {
+			msg.Headers[i].Value = []byte(traceID)
+			return
+		}
+	}
+	msg.Headers = append(msg.Headers, sarama.RecordHeader{
+		Key:   []byte(KafkaTraceIDHeader),
+		Value: []byte(traceID),
+	})
+}
+
+// TraceIDFromProducerMessage extract tid from headers of msg. return "" if not found.
+func TraceIDFromProducerMessage(msg *sarama.ProducerMessage) string {
+	if msg == nil {
+		return ""
+	}
+	for _, h := range msg.Headers {
+		if string(h.Key) == KafkaTraceIDHeader {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// TraceIDFromConsumerMessage extract tid from headers of msg. return "" if not found.
+func TraceIDFromConsumerMessage(msg *sarama.ConsumerMessage) string {
+	if msg == nil {
+		return ""
+	}
+	for _, h := range msg.Headers {
+		if h != nil && string(h.Key) == KafkaTraceIDHeader {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// CTXFromConsumerMessage creates a CTX with trace ID from headers of msg.
+// A new trace ID will be created by CTX.TraceID() if msg has none.
+func CTXFromConsumerMessage(msg *sarama.ConsumerMessage) *CTX {
+	return NewCTXWithTraceID(TraceIDFromConsumerMessage(msg))
+}
+
+// KafkaSyncProducer wraps sarama.SyncProducer. Trace ID of CTX is sent along with messages.
+type KafkaSyncProducer struct {
+	sarama.SyncProducer
+}
+
+func NewKafkaSyncProducer(sp sarama.SyncProducer) *KafkaSyncProducer {
+	DefaultLifecycle.RegisterKafkaProducer(sp)
+	return &KafkaSyncProducer{SyncProducer: sp}
+}
+
+// Send produces msg with header "tid" and waits for the result.
+func (p *KafkaSyncProducer) Send(ctx *CTX, msg *sarama.ProducerMessage) (partition int32, offset int64, err error) {
+	AppendTraceIDToProducerMessage(msg, ctx.TraceID())
+	partition, offset, err = p.SendMessage(msg)
+	if err != nil {
+		ctx.Logger().Error("Failed to produce message", zap.String("topic", msg.Topic), zap.Error(err))
+	}
+	return
+}
+
+// SendAll produces msgs with header "tid" and waits for the result.
+func (p *KafkaSyncProducer) SendAll(ctx *CTX, msgs []*sarama.ProducerMessage) error {
+	traceID := ctx.TraceID()
+	for _, msg := range msgs {
+		AppendTraceIDToProducerMessage(msg, traceID)
+	}
+	err := p.SendMessages(msgs)
+	if err != nil {
+		ctx.Logger().Error("Failed to produce messages", zap.Int("count", len(msgs)), zap.Error(err))
+	}
+	return err
+}
+
+// KafkaAsyncProducer wraps sarama.AsyncProducer. Trace ID of CTX is sent along with messages.
+// Errors() of the wrapped producer is drained and logged with trace ID by KafkaAsyncProducer,
+// so don't read Errors() by yourself.
+type KafkaAsyncProducer struct {
+	sarama.AsyncProducer
+}
+
+func NewKafkaAsyncProducer(ap sarama.AsyncProducer) *KafkaAsyncProducer {
+	p := &KafkaAsyncProducer{AsyncProducer: ap}
+	go p.logErrors()
+	DefaultLifecycle.RegisterKafkaProducer(ap)
+	return p
+}
+
+// Send enqueues msg with header "tid". Failures are logged asynchronously.
+func (p *KafkaAsyncProducer) Send(ctx *CTX, msg *sarama.ProducerMessage) {
+	AppendTraceIDToProducerMessage(msg, ctx.TraceID())
+	p.Input() <- msg
+}
+
+func (p *KafkaAsyncProducer) logErrors() {
+	for err := range p.Errors() {
+		if err == nil {
+			continue
+		}
+		var topic string
+		if err.Msg != nil {
+			topic = err.Msg.Topic
+		}
+		Logger.Error("Failed to produce message",
+			zap.String("tid", TraceIDFromProducerMessage(err.Msg)),
+			zap.String("topic", topic),
+			zap.Error(err.Err),
+		)
+	}
+}
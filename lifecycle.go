@@ -0,0 +1,258 @@
+package u
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/nsqio/go-nsq"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// ShutdownTimeout is the default deadline of GracefulShutdown.
+var ShutdownTimeout = 30 * time.Second
+
+// Lifecycle tracks resources that must be released when the service shuts down.
+// MustCreateNSQConsumer, MustCreateNSQProducer, DialGRPC, NewKafkaSyncProducer and NewKafkaAsyncProducer
+// register what they create with DefaultLifecycle. Every running CommonConsumer is tracked by the consumer registry.
+// Call the Unregister methods for resources released before shutdown, e.g. a GRPC connection dialed per request.
+// Closed GRPC connections and stopped NSQ consumers are dropped anyway when another one is registered.
+type Lifecycle struct {
+	mu             sync.Mutex
+	hooks          []func(ctx context.Context)
+	nsqConsumers   []*nsq.Consumer
+	nsqProducers   []*nsq.Producer
+	kafkaProducers []interface{ Close() error }
+	grpcConns      []*grpc.ClientConn
+	shutdownOnce   sync.Once
+}
+
+var DefaultLifecycle = NewLifecycle()
+
+func NewLifecycle() *Lifecycle {
+	return &Lifecycle{}
+}
+
+// OnShutdown registers a hook called first during Shutdown, e.g. stopping a http.Server.
+// Hooks are called in order of registration.
+func (l *Lifecycle) OnShutdown(hook func(ctx context.Context)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hooks = append(l.hooks, hook)
+}
+
+func (l *Lifecycle) RegisterNSQConsumer(c *nsq.Consumer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	consumers := l.nsqConsumers[:0]
+	for _, registered := range l.nsqConsumers {
+		if !isNSQConsumerStopped(registered) {
+			consumers = append(consumers, registered)
+		}
+	}
+	l.nsqConsumers = append(consumers, c)
+}
+
+func (l *Lifecycle) UnregisterNSQConsumer(c *nsq.Consumer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, registered := range l.nsqConsumers {
+		if registered == c {
+			l.nsqConsumers = append(l.nsqConsumers[:i], l.nsqConsumers[i+1:]...)
+			return
+		}
+	}
+}
+
+func (l *Lifecycle) RegisterNSQProducer(p *nsq.Producer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.nsqProducers = append(l.nsqProducers, p)
+}
+
+func (l *Lifecycle) UnregisterNSQProducer(p *nsq.Producer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, registered := range l.nsqProducers {
+		if registered == p {
+			l.nsqProducers = append(l.nsqProducers[:i], l.nsqProducers[i+1:]...)
+			return
+		}
+	}
+}
+
+// RegisterKafkaProducer accepts sarama.SyncProducer, sarama.AsyncProducer or their wrappers.
+func (l *Lifecycle) RegisterKafkaProducer(p interface{ Close() error }) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.kafkaProducers = append(l.kafkaProducers, p)
+}
+
+// UnregisterKafkaProducer must be called with the value passed to RegisterKafkaProducer.
+func (l *Lifecycle) UnregisterKafkaProducer(p interface{ Close() error }) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, registered := range l.kafkaProducers {
+		if registered == p {
+			l.kafkaProducers = append(l.kafkaProducers[:i], l.kafkaProducers[i+1:]...)
+			return
+		}
+	}
+}
+
+func (l *Lifecycle) RegisterGRPCConn(conn *grpc.ClientConn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	conns := l.grpcConns[:0]
+	for _, registered := range l.grpcConns {
+		if registered.GetState() != connectivity.Shutdown {
+			conns = append(conns, registered)
+		}
+	}
+	l.grpcConns = append(conns, conn)
+}
+
+func (l *Lifecycle) UnregisterGRPCConn(conn *grpc.ClientConn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, registered := range l.grpcConns {
+		if registered == conn {
+			l.grpcConns = append(l.grpcConns[:i], l.grpcConns[i+1:]...)
+			return
+		}
+	}
+}
+
+func isNSQConsumerStopped(c *nsq.Consumer) bool {
+	select {
+	case <-c.StopChan:
+		return true
+	default:
+		return false
+	}
+}
+
+// Shutdown releases resources in order:
+// calls hooks; cancels consumers and stops NSQ consumers, then waits for in-flight handlers;
+// flushes and closes producers; closes GRPC connections; syncs Logger.
+// Shutdown gives up waiting when ctx is done, and returns ctx.Err() in that case.
+// Only the first call takes effect.
+func (l *Lifecycle) Shutdown(ctx context.Context) (err error) {
+	l.shutdownOnce.Do(func() {
+		err = l.shutdown(ctx)
+	})
+	return
+}
+
+func (l *Lifecycle) shutdown(ctx context.Context) error {
+	l.mu.Lock()
+	hooks := append([]func(ctx context.Context){}, l.hooks...)
+	nsqConsumers := append([]*nsq.Consumer{}, l.nsqConsumers...)
+	nsqProducers := append([]*nsq.Producer{}, l.nsqProducers...)
+	kafkaProducers := append([]interface{ Close() error }{}, l.kafkaProducers...)
+	grpcConns := append([]*grpc.ClientConn{}, l.grpcConns...)
+	l.mu.Unlock()
+
+	Info("Shutting down...")
+
+	for _, hook := range hooks {
+		hook(ctx)
+	}
+
+	// Stop all consumers concurrently, then wait for their in-flight handlers.
+	var wg sync.WaitGroup
+	allConsumersMutex.RLock()
+	for _, c := range allConsumers {
+		wg.Add(1)
+		go func(c *CommonConsumer) {
+			defer wg.Done()
+			if err := c.CancelAndWait(ctx); err != nil {
+				Errorf("Failed to wait for consumer topic=%v; err=%v", c.Topic(), err)
+			}
+		}(c)
+	}
+	allConsumersMutex.RUnlock()
+	for _, c := range nsqConsumers {
+		if isNSQConsumerStopped(c) {
+			continue
+		}
+		wg.Add(1)
+		go func(c *nsq.Consumer) {
+			defer wg.Done()
+			c.Stop()
+			select {
+			case <-c.StopChan:
+			case <-ctx.Done():
+				Errorf("Failed to wait for NSQ consumer err=%v", ctx.Err())
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	// Closing may block, e.g. an AsyncProducer flushing to an unreachable broker. Stop waiting when ctx is done.
+	waitWithin(ctx, "Kafka producers", len(kafkaProducers), func(i int) {
+		if err := kafkaProducers[i].Close(); err != nil {
+			Errorf("Failed to close Kafka producer err=%v", err)
+		}
+	})
+	waitWithin(ctx, "NSQ producers", len(nsqProducers), func(i int) {
+		nsqProducers[i].Stop()
+	})
+	waitWithin(ctx, "GRPC connections", len(grpcConns), func(i int) {
+		conn := grpcConns[i]
+		if conn.GetState() == connectivity.Shutdown {
+			return
+		}
+		if err := conn.Close(); err != nil {
+			Debugf("Failed to close GRPC connection target=%v; err=%v", conn.Target(), err)
+		}
+	})
+
+	Info("Shutdown completed.")
+	// Sync may fail for stdout/stderr. Nothing to do with that.
+	_ = Logger.Sync()
+
+	return ctx.Err()
+}
+
+// waitWithin calls f(0) to f(n-1) concurrently, and waits until they return or ctx is done.
+func waitWithin(ctx context.Context, what string, n int, f func(i int)) {
+	if n == 0 {
+		return
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			f(i)
+		}(i)
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		Errorf("Failed to wait for %v err=%v", what, ctx.Err())
+	}
+}
+
+// GracefulShutdown blocks until SIGTERM or SIGINT is received, then calls DefaultLifecycle.Shutdown with ShutdownTimeout.
+func GracefulShutdown() error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	sig := <-signals
+	signal.Stop(signals)
+	Infof("Received signal %v", sig)
+
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+	return DefaultLifecycle.Shutdown(ctx)
+}
@@ -22,34 +22,50 @@ func init() {
 	ReloadLogger(nil)
 }
 
-func ReloadLogger(custom func(config *zap.Config /*, rotationCfg *lumberjack.Logger*/)) {
+// ReloadLogger rebuilds Logger. custom may change the default config.
+func ReloadLogger(custom func(config *zap.Config)) {
+	ReloadLoggerWithOutput(func(config *zap.Config, output *LogOutput) {
+		if custom != nil {
+			custom(config)
+		}
+	})
+}
+
+// ReloadLoggerWithOutput rebuilds Logger. custom may change the default config and add outputs such as rotated files.
+// Example:
+//
+//	ReloadLoggerWithOutput(func(config *zap.Config, output *LogOutput) {
+//		output.Stdout = true
+//		output.Sinks = []LogSink{
+//			{Filename: "./log/app.log", MaxSize: 10, MaxAge: 30, Compress: true},
+//			{Filename: "./log/error.log", Encoding: "json", MinLevel: "error"},
+//		}
+//	})
+func ReloadLoggerWithOutput(custom func(config *zap.Config, output *LogOutput)) {
 	var config = zap.NewProductionConfig()
 	config.Encoding = "console"
-	config.Level = zap.NewAtomicLevelAt(zap.DebugLevel)
+	// Level changed at runtime by SetLogLevel is kept.
+	config.Level = LogLevel
 	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 	config.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
 	config.EncoderConfig.EncodeCaller = nil
 
-	//rotationCfg := &lumberjack.Logger{
-	//	Filename: "./log/app.log",
-	//	MaxSize:  10,              // mb
-	//	MaxAge:   30,              // days
-	//}
+	var output LogOutput
 
 	if custom != nil {
-		custom(&config)
+		custom(&config, &output)
+	}
+	adoptLogLevel(&config)
+
+	if len(output.Sinks) == 0 && !output.Stdout {
+		logger, _ := config.Build()
+		setBaseLogger(logger)
+		replaceLogFiles(nil)
+	} else {
+		logger, files := buildLoggerWithOutput(config, output)
+		setBaseLogger(logger)
+		replaceLogFiles(files)
 	}
-
-	//core := zapcore.NewCore(
-	//	zapcore.NewConsoleEncoder(config.EncoderConfig),
-	//	zapcore.AddSync(rotationCfg),
-	//	config.Level,
-	//)
-	//
-	//logger := zap.New(core, zap.AddCaller(), zap.AddCallerSkip())
-
-	Logger, _ = config.Build()
-	L = Logger.Sugar()
 }
 
 func LogLevelOfString(str string) zapcore.Level {
@@ -73,15 +89,11 @@ func LogLevelOfString(str string) zapcore.Level {
 	return zapcore.InfoLevel
 }
 
-func SetLogLevel(level zapcore.Level) {
-	ReloadLogger(func(config *zap.Config) {
-		config.Level = zap.NewAtomicLevelAt(level)
-	})
-}
-
+// ReconfigLogger rebuilds Logger with config. Level of config becomes LogLevel.
 func ReconfigLogger(config zap.Config) {
-	Logger, _ = config.Build()
-	L = Logger.Sugar()
+	adoptLogLevel(&config)
+	logger, _ := config.Build()
+	setBaseLogger(logger)
 }
 
 func Debug(args ...interface{}) {
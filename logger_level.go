@@ -0,0 +1,198 @@
+package u
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LogLevel is the level of Logger, shared by every rebuild of Logger. See SetLogLevel.
+var LogLevel = zap.NewAtomicLevelAt(zap.DebugLevel)
+
+// Subsystems of this package which may log at their own level. See SetSubsystemLogLevel.
+const (
+	LogSubsystemGin      = "gin"
+	LogSubsystemSQL      = "sql"
+	LogSubsystemGRPC     = "grpc"
+	LogSubsystemConsumer = "consumer"
+)
+
+var logSubsystems = []string{LogSubsystemGin, LogSubsystemSQL, LogSubsystemGRPC, LogSubsystemConsumer}
+
+func isLogSubsystem(subsystem string) bool {
+	for _, s := range logSubsystems {
+		if s == subsystem {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	// baseLogger enables all levels. Logger and subsystem loggers filter on top of it.
+	baseLogger *zap.Logger
+
+	subsystemLevels  = map[string]zap.AtomicLevel{}
+	subsystemLoggers = map[string]*zap.Logger{}
+	subsystemMutex   sync.RWMutex
+)
+
+// levelFilterCore filters entries by enabler before they reach the wrapped core.
+type levelFilterCore struct {
+	zapcore.Core
+	enabler zapcore.LevelEnabler
+}
+
+func (c levelFilterCore) Enabled(l zapcore.Level) bool {
+	return c.enabler.Enabled(l) && c.Core.Enabled(l)
+}
+
+func (c levelFilterCore) With(fields []zapcore.Field) zapcore.Core {
+	return levelFilterCore{Core: c.Core.With(fields), enabler: c.enabler}
+}
+
+func (c levelFilterCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.enabler.Enabled(ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+func withLevel(logger *zap.Logger, enabler zapcore.LevelEnabler) *zap.Logger {
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return levelFilterCore{Core: core, enabler: enabler}
+	}))
+}
+
+// adoptLogLevel makes config use LogLevel, taking the level set by custom config if any.
+// Then config enables all levels, since filtering is done by levelFilterCore.
+func adoptLogLevel(config *zap.Config) {
+	if config.Level != (zap.AtomicLevel{}) && config.Level != LogLevel {
+		LogLevel.SetLevel(config.Level.Level())
+	}
+	config.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+}
+
+// setBaseLogger sets Logger, L and subsystem loggers.
+func setBaseLogger(logger *zap.Logger) {
+	subsystemMutex.Lock()
+	defer subsystemMutex.Unlock()
+	baseLogger = logger
+	subsystemLoggers = map[string]*zap.Logger{}
+	Logger = withLevel(logger, LogLevel)
+	L = Logger.Sugar()
+}
+
+// SetLogLevel changes level of Logger at runtime. Logger is not rebuilt.
+// Subsystems without their own level follow it.
+func SetLogLevel(level zapcore.Level) {
+	LogLevel.SetLevel(level)
+}
+
+// SetSubsystemLogLevel overrides level of a subsystem, e.g. SetSubsystemLogLevel(LogSubsystemSQL, zap.DebugLevel)
+// turns on SQL debug logs alone.
+func SetSubsystemLogLevel(subsystem string, level zapcore.Level) {
+	subsystemMutex.Lock()
+	defer subsystemMutex.Unlock()
+	if l, ok := subsystemLevels[subsystem]; ok {
+		l.SetLevel(level)
+		return
+	}
+	subsystemLevels[subsystem] = zap.NewAtomicLevelAt(level)
+	delete(subsystemLoggers, subsystem)
+}
+
+// ResetSubsystemLogLevel makes the subsystem follow LogLevel again.
+func ResetSubsystemLogLevel(subsystem string) {
+	subsystemMutex.Lock()
+	defer subsystemMutex.Unlock()
+	delete(subsystemLevels, subsystem)
+	delete(subsystemLoggers, subsystem)
+}
+
+// SubsystemLogger returns a logger of the subsystem, named after it.
+func SubsystemLogger(subsystem string) *zap.Logger {
+	subsystemMutex.RLock()
+	logger, ok := subsystemLoggers[subsystem]
+	subsystemMutex.RUnlock()
+	if ok {
+		return logger
+	}
+
+	subsystemMutex.Lock()
+	defer subsystemMutex.Unlock()
+	if logger, ok = subsystemLoggers[subsystem]; ok {
+		return logger
+	}
+	var enabler zapcore.LevelEnabler = LogLevel
+	if l, ok := subsystemLevels[subsystem]; ok {
+		enabler = l
+	}
+	logger = withLevel(baseLogger, enabler).Named(subsystem)
+	subsystemLoggers[subsystem] = logger
+	return logger
+}
+
+// LogLevels returns level of Logger with key "" and levels of subsystems with their own level.
+func LogLevels() map[string]string {
+	subsystemMutex.RLock()
+	defer subsystemMutex.RUnlock()
+	levels := make(map[string]string, len(subsystemLevels)+1)
+	levels[""] = LogLevel.String()
+	for s, l := range subsystemLevels {
+		levels[s] = l.String()
+	}
+	return levels
+}
+
+// ChangeLogLevel changes level of Logger, or of the subsystem if subsystem is not empty.
+// subsystem is one of LogSubsystem*, and level is one of debug, info, warn, error, dpanic, panic and fatal.
+// It is meant to be called by a GRPC service or any other admin interface. Use SetSubsystemLogLevel for other subsystems.
+func ChangeLogLevel(subsystem string, level string) ErrorType {
+	var l zapcore.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return ErrInvalidLogLevel(err)
+	}
+	if subsystem != "" && !isLogSubsystem(subsystem) {
+		return ErrInvalidLogLevel(fmt.Sprintf("unknown subsystem %q, expect one of %v", subsystem, logSubsystems))
+	}
+	if subsystem == "" {
+		SetLogLevel(l)
+	} else {
+		SetSubsystemLogLevel(subsystem, l)
+	}
+	Infof("Log level changed. subsystem=%q; level=%v", subsystem, l)
+	return nil
+}
+
+// RegisterLogLevelRoutes registers routes for reading and changing log levels at runtime.
+//
+//	GET /log-level  responds {"error": null, "levels": {"": "info", "sql": "debug"}}
+//	PUT /log-level  with body {"level": "debug", "subsystem": "sql"}. Omit subsystem for Logger.
+//
+// Authorization is up to the caller.
+func RegisterLogLevelRoutes(routes gin.IRoutes) {
+	routes.GET("/log-level", LogLevelsHandler)
+	routes.PUT("/log-level", ChangeLogLevelHandler)
+}
+
+func LogLevelsHandler(c *gin.Context) {
+	h := NewGinHelper(c)
+	h.RespondKV200("levels", LogLevels(), nil)
+}
+
+func ChangeLogLevelHandler(c *gin.Context) {
+	h := NewGinHelper(c)
+	var req struct {
+		Level     string `json:"level" binding:"required"`
+		Subsystem string `json:"subsystem"`
+	}
+	if !h.MustBind(&req) {
+		return
+	}
+	erro := ChangeLogLevel(req.Subsystem, req.Level)
+	h.RespondKV200("levels", LogLevels(), erro)
+}
@@ -0,0 +1,152 @@
+package u
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// LogOutput configures where Logger writes. See ReloadLoggerWithOutput.
+// If Sinks is empty and Stdout is false, OutputPaths of zap.Config are used as before.
+type LogOutput struct {
+	// Sinks are files that logs are written to.
+	Sinks []LogSink
+
+	// Stdout tees logs to stdout with Encoding of zap.Config.
+	Stdout bool
+}
+
+// LogSink is a log file with size- and age-based rotation.
+// Example, errors to their own file in JSON:
+//
+//	LogSink{Filename: "./log/error.log", Encoding: "json", MinLevel: "error"}
+type LogSink struct {
+	// Filename is the file to write logs to. Backups use the same directory.
+	Filename string
+
+	// Encoding is "console" or "json". Default is "console".
+	Encoding string
+
+	// MinLevel and MaxLevel limit levels written to this sink, see LogLevelOfString. Empty means no limit.
+	// Level of zap.Config applies anyway.
+	MinLevel string
+	MaxLevel string
+
+	// MaxSize is the maximum size in megabytes of the log file before it gets rotated. Default is 100.
+	MaxSize int
+
+	// MaxAge is the maximum number of days to retain old log files. Zero means not to remove old files based on age.
+	MaxAge int
+
+	// MaxBackups is the maximum number of old log files to retain. Zero means to retain all.
+	MaxBackups int
+
+	// Compress rotated files with gzip.
+	Compress bool
+}
+
+// openedLogFiles are closed when Logger is reloaded.
+var openedLogFiles []*lumberjack.Logger
+var openedLogFilesMutex sync.Mutex
+
+func (s LogSink) levelEnabler(level zap.AtomicLevel) zapcore.LevelEnabler {
+	return zap.LevelEnablerFunc(func(l zapcore.Level) bool {
+		if !level.Enabled(l) {
+			return false
+		}
+		if s.MinLevel != "" && l < LogLevelOfString(s.MinLevel) {
+			return false
+		}
+		if s.MaxLevel != "" && l > LogLevelOfString(s.MaxLevel) {
+			return false
+		}
+		return true
+	})
+}
+
+func newLogEncoder(encoding string, encoderConfig zapcore.EncoderConfig) zapcore.Encoder {
+	if encoding == "json" {
+		return zapcore.NewJSONEncoder(encoderConfig)
+	}
+	return zapcore.NewConsoleEncoder(encoderConfig)
+}
+
+// buildLoggerWithOutput builds a logger the same way as zap.Config.Build, but writes to output.
+func buildLoggerWithOutput(config zap.Config, output LogOutput) (*zap.Logger, []*lumberjack.Logger) {
+	// JSON encoder requires EncodeCaller if CallerKey is set. ReloadLogger sets it nil to omit caller.
+	if config.EncoderConfig.EncodeCaller == nil {
+		config.EncoderConfig.CallerKey = zapcore.OmitKey
+	}
+	// Color codes are unreadable in files.
+	fileEncoderConfig := config.EncoderConfig
+	fileEncoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+
+	cores := make([]zapcore.Core, 0, len(output.Sinks)+1)
+	files := make([]*lumberjack.Logger, 0, len(output.Sinks))
+	for _, sink := range output.Sinks {
+		file := &lumberjack.Logger{
+			Filename:   sink.Filename,
+			MaxSize:    sink.MaxSize,
+			MaxAge:     sink.MaxAge,
+			MaxBackups: sink.MaxBackups,
+			Compress:   sink.Compress,
+			LocalTime:  true,
+		}
+		files = append(files, file)
+		cores = append(cores, zapcore.NewCore(
+			newLogEncoder(sink.Encoding, fileEncoderConfig),
+			zapcore.AddSync(file),
+			sink.levelEnabler(config.Level),
+		))
+	}
+	if output.Stdout {
+		cores = append(cores, zapcore.NewCore(
+			newLogEncoder(config.Encoding, config.EncoderConfig),
+			zapcore.Lock(os.Stdout),
+			config.Level,
+		))
+	}
+
+	core := zapcore.NewTee(cores...)
+	if config.Sampling != nil {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, config.Sampling.Initial, config.Sampling.Thereafter)
+	}
+
+	opts := []zap.Option{zap.ErrorOutput(zapcore.Lock(os.Stderr))}
+	if config.Development {
+		opts = append(opts, zap.Development())
+	}
+	if !config.DisableCaller {
+		opts = append(opts, zap.AddCaller())
+	}
+	if !config.DisableStacktrace {
+		stackLevel := zap.ErrorLevel
+		if config.Development {
+			stackLevel = zap.WarnLevel
+		}
+		opts = append(opts, zap.AddStacktrace(stackLevel))
+	}
+	if len(config.InitialFields) > 0 {
+		fields := make([]zap.Field, 0, len(config.InitialFields))
+		for k, v := range config.InitialFields {
+			fields = append(fields, zap.Any(k, v))
+		}
+		opts = append(opts, zap.Fields(fields...))
+	}
+	return zap.New(core, opts...), files
+}
+
+// replaceLogFiles closes files opened by the previous Logger.
+func replaceLogFiles(files []*lumberjack.Logger) {
+	openedLogFilesMutex.Lock()
+	old := openedLogFiles
+	openedLogFiles = files
+	openedLogFilesMutex.Unlock()
+	for _, f := range old {
+		_ = f.Close()
+	}
+}
@@ -0,0 +1,79 @@
+package u
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics are registered to prometheus.DefaultRegisterer, same as grpc_prometheus metrics of DialGRPC.
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_server_requests_total",
+		Help: "Total number of HTTP requests handled, by route template, method and status.",
+	}, []string{"route", "method", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_server_request_duration_seconds",
+		Help:    "Latency of HTTP requests, by route template, method and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	httpErrorResponsesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_server_error_responses_total",
+		Help: "Total number of error responses, by ErrorCode() and status.",
+	}, []string{"code", "status"})
+
+	sqlQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sql_query_duration_seconds",
+		Help:    "Duration of SQL queries made by DBXWithLogger and TXXWithLogger, by call site.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"file"})
+
+	sqlSlowQueriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sql_slow_queries_total",
+		Help: "Total number of queries slower than SlowSQLDuration, by call site and level (slow or very_slow).",
+	}, []string{"file", "level"})
+)
+
+// unmatchedRoute is the route label of requests that match no route, which keeps cardinality of labels bounded.
+const unmatchedRoute = "unmatched"
+
+// MetricsHandler exposes all metrics of prometheus.DefaultGatherer.
+// Example:
+//
+//	router.GET("/metrics", u.MetricsHandler())
+func MetricsHandler() gin.HandlerFunc {
+	h := promhttp.Handler()
+	return func(c *gin.Context) {
+		h.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+func observeGinRequest(c *gin.Context, begin time.Time) {
+	route := c.FullPath()
+	if route == "" {
+		route = unmatchedRoute
+	}
+	status := strconv.Itoa(c.Writer.Status())
+	httpRequestsTotal.WithLabelValues(route, c.Request.Method, status).Inc()
+	httpRequestDuration.WithLabelValues(route, c.Request.Method, status).Observe(time.Since(begin).Seconds())
+}
+
+func observeErrorResponse(erro ErrorType) {
+	httpErrorResponsesTotal.WithLabelValues(fmt.Sprintf("%v", erro.ErrorCode()), strconv.Itoa(erro.StatusCode())).Inc()
+}
+
+func observeSQL(file string, elapsed time.Duration) {
+	sqlQueryDuration.WithLabelValues(file).Observe(elapsed.Seconds())
+	if elapsed >= VerySlowSQLDuration {
+		sqlSlowQueriesTotal.WithLabelValues(file, "very_slow").Inc()
+	} else if elapsed >= SlowSQLDuration {
+		sqlSlowQueriesTotal.WithLabelValues(file, "slow").Inc()
+	}
+}
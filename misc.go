@@ -2,11 +2,13 @@ package u
 
 import (
 	"encoding/json"
+	"fmt"
 	"reflect"
 	"strings"
 	"unsafe"
 
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
 // AvroNameFor if given name does not start with [A-Za-z_], returned avro name will start with a prefix '_'.
@@ -136,11 +138,7 @@ func ShortUUID(length int) string {
 func AutoRecover(ctx *CTX, job func()) {
 	defer func() {
 		if err := recover(); err != nil {
-			var traceID string
-			if ctx != nil {
-				traceID = ctx.traceID
-			}
-			Errorf("[%v] %v", traceID, err)
+			logRecovered(ctx, err)
 		}
 	}()
 	job()
@@ -149,16 +147,24 @@ func AutoRecover(ctx *CTX, job func()) {
 func AutoRecoverReturns[T any](ctx *CTX, job func() T) T {
 	defer func() {
 		if err := recover(); err != nil {
-			var traceID string
-			if ctx != nil {
-				traceID = ctx.traceID
-			}
-			Errorf("[%v] %v", traceID, err)
+			logRecovered(ctx, err)
 		}
 	}()
 	return job()
 }
 
+func logRecovered(ctx *CTX, err interface{}) {
+	logger := Logger
+	if ctx != nil {
+		logger = ctx.Logger()
+	}
+	var fields []zap.Field
+	if erro, ok := err.(ErrorType); ok {
+		fields = append(fields, zap.Any("code", erro.ErrorCode()), zap.Int("status", erro.StatusCode()))
+	}
+	logger.Error(fmt.Sprintf("recovered: %v", err), fields...)
+}
+
 func AutoRecoverAsync(ctx *CTX, job func()) {
 	go func() {
 		AutoRecover(ctx, job)
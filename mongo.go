@@ -10,7 +10,14 @@ import (
 
 // MongoCollectionMustExist return true if collection exists.
 func MongoCollectionMustExist(mongoDB *mongo.Database, name string) bool {
-	names, err := mongoDB.ListCollectionNames(context.Background(), bson.M{"name": name})
+	return MongoCollectionMustExistWithContext(context.Background(), mongoDB, name)
+}
+
+// MongoCollectionMustExistWithContext is MongoCollectionMustExist with ctx, e.g. a *CTX.
+func MongoCollectionMustExistWithContext(ctx context.Context, mongoDB *mongo.Database, name string) bool {
+	ctx, span := startMongoSpan(ctx, "listCollections", name)
+	names, err := mongoDB.ListCollectionNames(ctx, bson.M{"name": name})
+	endSpanWithError(span, err)
 	if err != nil {
 		Panic(ErrMongoQueryErr(err))
 	}
@@ -19,10 +26,23 @@ func MongoCollectionMustExist(mongoDB *mongo.Database, name string) bool {
 
 // MustSetupMongoCollection creates collection if not exists.
 func MustSetupMongoCollection(mongoDB *mongo.Database, name string, validator bson.M, indexes []mongo.IndexModel) {
+	MustSetupMongoCollectionWithContext(context.Background(), mongoDB, name, validator, indexes)
+}
+
+// MustSetupMongoCollectionWithContext is MustSetupMongoCollection with ctx, e.g. a *CTX.
+func MustSetupMongoCollectionWithContext(ctx context.Context, mongoDB *mongo.Database, name string, validator bson.M, indexes []mongo.IndexModel) {
+	ctx, span := startMongoSpan(ctx, "setupCollection", name)
+	defer func() {
+		if err := recover(); err != nil {
+			endSpanWithError(span, err)
+			panic(err)
+		}
+		span.End()
+	}()
 	c := mongoDB.Collection(name, nil)
-	if MongoCollectionMustExist(mongoDB, name) {
+	if MongoCollectionMustExistWithContext(ctx, mongoDB, name) {
 		// update validator
-		result := mongoDB.RunCommand(context.Background(), bson.D{
+		result := mongoDB.RunCommand(ctx, bson.D{
 			{"collMod", name},
 			{"validator", validator},
 		})
@@ -33,7 +53,7 @@ func MustSetupMongoCollection(mongoDB *mongo.Database, name string, validator bs
 		// create indexes is an idempotent operation.
 		if len(indexes) > 0 {
 			iv := c.Indexes()
-			_, err := iv.CreateMany(context.Background(), indexes)
+			_, err := iv.CreateMany(ctx, indexes)
 			if err != nil {
 				Panic(ErrMongoWriteErr(err))
 			}
@@ -44,7 +64,7 @@ func MustSetupMongoCollection(mongoDB *mongo.Database, name string, validator bs
 	// create collection and validator
 	opts := options.CreateCollection()
 	opts.Validator = validator
-	err := mongoDB.CreateCollection(context.Background(), name, opts)
+	err := mongoDB.CreateCollection(ctx, name, opts)
 	if err != nil {
 		Panic(ErrMongoWriteErr(err))
 	}
@@ -52,7 +72,7 @@ func MustSetupMongoCollection(mongoDB *mongo.Database, name string, validator bs
 	// create indexes
 	if len(indexes) > 0 {
 		iv := c.Indexes()
-		_, err = iv.CreateMany(context.Background(), indexes)
+		_, err = iv.CreateMany(ctx, indexes)
 		if err != nil {
 			Panic(ErrMongoWriteErr(err))
 		}
@@ -41,6 +41,8 @@ func MustCreateNSQConsumer(topic string, handler nsq.Handler, channel, lookupdSv
 		panic(err)
 	}
 
+	DefaultLifecycle.RegisterNSQConsumer(c)
+
 	return c
 }
 
@@ -54,5 +56,7 @@ func MustCreateNSQProducer(nsqdSvr string, logLevel nsq.LogLevel) *nsq.Producer
 
 	p.SetLoggerLevel(logLevel)
 
+	DefaultLifecycle.RegisterNSQProducer(p)
+
 	return p
 }
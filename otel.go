@@ -0,0 +1,252 @@
+package u
+
+import (
+	"context"
+	"crypto/rand"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const tracerName = "github.com/simplefelix/u"
+
+// TracerProvider provides tracers for spans created by this package.
+// nil means otel.GetTracerProvider(), which does nothing until a provider is set by otel.SetTracerProvider.
+// Example for tests:
+//
+//	exporter := tracetest.NewInMemoryExporter()
+//	u.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+var TracerProvider trace.TracerProvider
+
+func tracer() trace.Tracer {
+	tp := TracerProvider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(tracerName)
+}
+
+// remoteSpanContext returns a SpanContext of trace ID of CTX, so that spans share the trace ID in logs.
+// Returns an invalid SpanContext if traceID is not hex. See w3cTraceID.
+func remoteSpanContext(traceID, spanID string, notSampled bool) trace.SpanContext {
+	id, ok := w3cTraceID(traceID)
+	if !ok {
+		return trace.SpanContext{}
+	}
+	tid, err := trace.TraceIDFromHex(id)
+	if err != nil {
+		return trace.SpanContext{}
+	}
+	sid, err := trace.SpanIDFromHex(spanID)
+	if err != nil {
+		return trace.SpanContext{}
+	}
+	var flags trace.TraceFlags
+	if !notSampled {
+		flags = trace.FlagsSampled
+	}
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    tid,
+		SpanID:     sid,
+		TraceFlags: flags,
+		Remote:     true,
+	})
+}
+
+// spanParent returns ctx if it already carries a span.
+// Otherwise if ctx is a *CTX, the span of CTX becomes the parent.
+func spanParent(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	if c, ok := ctx.(*CTX); ok {
+		if sc := remoteSpanContext(c.TraceID(), c.SpanID(), c.notSampled); sc.IsValid() {
+			return trace.ContextWithRemoteSpanContext(ctx, sc)
+		}
+	}
+	return ctx
+}
+
+// traceIDAttribute returns attribute "tid" of ctx if ctx is a *CTX.
+func traceIDAttributes(ctx context.Context) []attribute.KeyValue {
+	if c, ok := ctx.(*CTX); ok {
+		return []attribute.KeyValue{attribute.String("tid", c.TraceID())}
+	}
+	return nil
+}
+
+// rootTraceIDKey is the context key of the trace ID that CTXIDGenerator gives to a root span.
+type rootTraceIDKey struct{}
+
+// CTXIDGenerator gives root server spans started by GinMiddleware the trace ID of CTX,
+// so that logs and traces share trace IDs even if the caller sends no traceparent or b3.
+// Without it, such spans have trace IDs of their own, and the trace ID of CTX is only recorded in attribute "tid".
+// Example:
+//
+//	u.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithIDGenerator(u.CTXIDGenerator{}), sdktrace.WithBatcher(exporter))
+type CTXIDGenerator struct{}
+
+func (CTXIDGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	if id, ok := ctx.Value(rootTraceIDKey{}).(string); ok {
+		if tid, err := trace.TraceIDFromHex(id); err == nil {
+			return tid, randomSpanID()
+		}
+	}
+	var tid trace.TraceID
+	_, _ = rand.Read(tid[:])
+	return tid, randomSpanID()
+}
+
+func (CTXIDGenerator) NewSpanID(ctx context.Context, traceID trace.TraceID) trace.SpanID {
+	return randomSpanID()
+}
+
+func randomSpanID() trace.SpanID {
+	var sid trace.SpanID
+	_, _ = rand.Read(sid[:])
+	return sid
+}
+
+// startServerSpan starts a span for the request handled by this service. The caller's span is the parent.
+// CTX wraps the new span so that spans started with CTX are its children.
+// Trace ID of CTX is never replaced, since it may have been written to logs already, e.g. by GinLogger. See CTXIDGenerator.
+func startServerSpan(c *CTX, name string, attrs ...attribute.KeyValue) trace.Span {
+	parent := c.Context()
+	if sc := remoteSpanContext(c.TraceID(), c.parentSpanID, c.notSampled); sc.IsValid() {
+		parent = trace.ContextWithRemoteSpanContext(parent, sc)
+	} else if id, ok := w3cTraceID(c.TraceID()); ok {
+		parent = context.WithValue(parent, rootTraceIDKey{}, id)
+	}
+	spanCtx, span := tracer().Start(parent, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+	c.parent = spanCtx
+	if id, _ := w3cTraceID(c.TraceID()); span.IsRecording() && id == span.SpanContext().TraceID().String() {
+		// Span ID of CTX is propagated to callees as their parent span.
+		c.spanID = span.SpanContext().SpanID().String()
+	}
+	span.SetAttributes(attribute.String("tid", c.TraceID()))
+	return span
+}
+
+// ginTracing starts a server span for the request. Call the returned function after c.Next().
+func ginTracing(c *gin.Context) func() {
+	ctx := getCTX(c)
+	name := c.FullPath()
+	if name == "" {
+		name = "unmatched route"
+	}
+	span := startServerSpan(ctx, c.Request.Method+" "+name,
+		attribute.String("http.method", c.Request.Method),
+		attribute.String("http.route", c.FullPath()),
+		attribute.String("http.target", c.Request.URL.Path),
+		attribute.String("net.peer.ip", c.ClientIP()),
+	)
+	c.Request = c.Request.WithContext(ctx.Context())
+	return func() {
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		span.End()
+	}
+}
+
+// sqlSpan records a span for a query which began at begin and ends now.
+// Without a *CTX or span in ctx, the span joins the trace of traceID, which is the one in SQL logs.
+func sqlSpan(ctx context.Context, traceID string, begin time.Time, file string, query string) {
+	attrs := []attribute.KeyValue{
+		attribute.String("tid", traceID),
+		attribute.String("db.statement", query),
+		attribute.String("code.filepath", file),
+	}
+	parent := spanParent(ctx)
+	if _, ok := ctx.(*CTX); !ok && !trace.SpanContextFromContext(parent).IsValid() {
+		if sc := remoteSpanContext(traceID, NewSpanID(), false); sc.IsValid() {
+			parent = trace.ContextWithRemoteSpanContext(parent, sc)
+		}
+	}
+	_, span := tracer().Start(parent, "sql", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(begin), trace.WithAttributes(attrs...))
+	span.End()
+}
+
+// startMongoSpan starts a span for a Mongo operation. The returned context carries the span.
+func startMongoSpan(ctx context.Context, operation string, collection string) (context.Context, trace.Span) {
+	attrs := append(traceIDAttributes(ctx),
+		attribute.String("db.system", "mongodb"),
+		attribute.String("db.operation", operation),
+		attribute.String("db.mongodb.collection", collection),
+	)
+	return tracer().Start(spanParent(ctx), "mongo."+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+func endSpanWithError(span trace.Span, err interface{}) {
+	if err != nil {
+		if e, ok := err.(error); ok {
+			span.RecordError(e)
+		}
+		span.SetStatus(codes.Error, "")
+	}
+	span.End()
+}
+
+// startGRPCClientSpan starts a client span. Outgoing traceparent and b3 are replaced so that the new span is the callee's parent.
+func startGRPCClientSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+	ctx, span := tracer().Start(spanParent(ctx), method, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(append(traceIDAttributes(ctx),
+			attribute.String("rpc.system", "grpc"),
+			attribute.String("rpc.method", method),
+		)...))
+	if !span.IsRecording() {
+		return ctx, span
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	traceID := TraceIDFromMD(md)
+	if traceID == "" {
+		traceID = span.SpanContext().TraceID().String()
+	}
+	kv := traceHeaders(traceID, span.SpanContext().SpanID().String(), span.SpanContext().TraceState().String(), !span.SpanContext().IsSampled())
+	for i := 0; i+1 < len(kv); i += 2 {
+		md.Set(kv[i], kv[i+1])
+	}
+	return metadata.NewOutgoingContext(ctx, md), span
+}
+
+func endGRPCClientSpan(span trace.Span, err error) {
+	if err != nil {
+		s, _ := status.FromError(err)
+		span.SetAttributes(attribute.String("rpc.grpc.status_code", s.Code().String()))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, s.Message())
+	}
+	span.End()
+}
+
+// GRPCTracingUnaryClientInterceptor creates a client span for each call. Used by DialGRPC.
+func GRPCTracingUnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	ctx, span := startGRPCClientSpan(ctx, method)
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	endGRPCClientSpan(span, err)
+	return err
+}
+
+// GRPCTracingStreamClientInterceptor creates a client span for each stream. The span ends when the stream is created.
+// Used by DialGRPC.
+func GRPCTracingStreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	ctx, span := startGRPCClientSpan(ctx, method)
+	s, err := streamer(ctx, desc, cc, method, opts...)
+	endGRPCClientSpan(span, err)
+	return s, err
+}
@@ -0,0 +1,236 @@
+package u
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RedactionPolicy masks sensitive data in request dumps of respondError and GinLogger, and in args logged by SQLTrace.
+type RedactionPolicy struct {
+	// Mask replaces sensitive values.
+	Mask string
+
+	// Headers are names of headers to mask. Case-insensitive.
+	Headers []string
+
+	// JSONFields are paths of fields to mask in JSON request bodies.
+	// A path without dot, e.g. "password", matches fields of the name at any depth.
+	// A dotted path, e.g. "user.card.number", matches from the root. "*" matches any key or array index.
+	JSONFields []string
+
+	// SQLArgPositions are 0-based positions of SQL arguments to mask.
+	SQLArgPositions []int
+
+	// SQLColumns are names of columns whose arguments are masked. Case-insensitive.
+	// Columns are recognized in "column = ?" conditions and assignments, "column IN (?, ?)",
+	// and "INSERT INTO t (columns) VALUES (?...)".
+	SQLColumns []string
+}
+
+// DefaultRedactionPolicy masks Authorization and cookies in headers, and password in JSON bodies and SQL arguments.
+func DefaultRedactionPolicy() RedactionPolicy {
+	return RedactionPolicy{
+		Mask:       "******",
+		Headers:    []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"},
+		JSONFields: []string{"password"},
+		SQLColumns: []string{"password"},
+	}
+}
+
+// Redaction applies to all logs of this package. Set it during initialization.
+var Redaction = DefaultRedactionPolicy()
+
+func (p RedactionPolicy) isSensitiveHeader(name string) bool {
+	for _, h := range p.Headers {
+		if strings.EqualFold(h, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactHeader returns "Name: value" lines of header.
+func (p RedactionPolicy) redactHeader(header http.Header) []string {
+	lines := make([]string, 0, len(header))
+	for k, vs := range header {
+		sensitive := p.isSensitiveHeader(k)
+		for _, v := range vs {
+			if sensitive {
+				v = p.Mask
+			}
+			lines = append(lines, k+": "+v)
+		}
+	}
+	return lines
+}
+
+// RedactJSON masks JSONFields in body. If body is not valid JSON, e.g. truncated, string values of fields are masked by name.
+// Numbers are kept as they are, so large IDs do not lose precision.
+func (p RedactionPolicy) RedactJSON(body []byte) string {
+	if len(p.JSONFields) == 0 || len(body) == 0 {
+		return string(body)
+	}
+	var v interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil || decoder.More() {
+		return p.redactJSONText(string(body))
+	}
+	for _, path := range p.JSONFields {
+		segments := strings.Split(path, ".")
+		if len(segments) == 1 {
+			v = p.redactFieldAtAnyDepth(v, path)
+		} else {
+			v = p.redactPath(v, segments)
+		}
+	}
+	redacted, err := json.Marshal(v)
+	if err != nil {
+		return p.redactJSONText(string(body))
+	}
+	return string(redacted)
+}
+
+func (p RedactionPolicy) redactFieldAtAnyDepth(v interface{}, name string) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			if k == name {
+				t[k] = p.Mask
+			} else {
+				t[k] = p.redactFieldAtAnyDepth(child, name)
+			}
+		}
+	case []interface{}:
+		for i, child := range t {
+			t[i] = p.redactFieldAtAnyDepth(child, name)
+		}
+	}
+	return v
+}
+
+func (p RedactionPolicy) redactPath(v interface{}, segments []string) interface{} {
+	if len(segments) == 0 {
+		return p.Mask
+	}
+	segment, rest := segments[0], segments[1:]
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			if segment == "*" || segment == k {
+				t[k] = p.redactPath(child, rest)
+			}
+		}
+	case []interface{}:
+		for i, child := range t {
+			if segment == "*" || segment == strconv.Itoa(i) {
+				t[i] = p.redactPath(child, rest)
+			}
+		}
+	}
+	return v
+}
+
+// jsonTextRegexps caches regexps of redactJSONText by field name, since Redaction is usually set once.
+var jsonTextRegexps sync.Map
+
+func jsonTextRegexp(name string) *regexp.Regexp {
+	if re, ok := jsonTextRegexps.Load(name); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(`("` + regexp.QuoteMeta(name) + `"\s*:\s*)"(?:[^"\\]|\\.)*("|$)`)
+	jsonTextRegexps.Store(name, re)
+	return re
+}
+
+// redactJSONText masks string values of the last segment of JSONFields, e.g. "password": "secret".
+func (p RedactionPolicy) redactJSONText(text string) string {
+	for _, path := range p.JSONFields {
+		name := path[strings.LastIndex(path, ".")+1:]
+		if name == "*" {
+			continue
+		}
+		text = jsonTextRegexp(name).ReplaceAllString(text, `${1}"`+p.Mask+`"`)
+	}
+	return text
+}
+
+var (
+	sqlPlaceholderRegexp = regexp.MustCompile(`\?|\$\d+`)
+	sqlConditionRegexp   = regexp.MustCompile(`(?i)([\w.` + "`" + `"]+)\s*(?:=|<>|!=|<=|>=|<|>|\blike)\s*$`)
+	sqlInRegexp          = regexp.MustCompile(`(?i)([\w.` + "`" + `"]+)\s+(?:not\s+)?in\s*\(\s*(?:(?:\?|\$\d+)\s*,\s*)*$`)
+	sqlInsertRegexp      = regexp.MustCompile(`(?is)insert\s+(?:ignore\s+)?into\s+[^(]+\(([^)]*)\)\s*values\s*\(`)
+)
+
+// RedactSQLArgs returns a copy of args with sensitive arguments masked.
+func (p RedactionPolicy) RedactSQLArgs(query string, args []interface{}) []interface{} {
+	if len(args) == 0 || (len(p.SQLArgPositions) == 0 && len(p.SQLColumns) == 0) {
+		return args
+	}
+	redacted := make([]interface{}, len(args))
+	copy(redacted, args)
+	for _, i := range p.SQLArgPositions {
+		if i >= 0 && i < len(redacted) {
+			redacted[i] = p.Mask
+		}
+	}
+	if len(p.SQLColumns) > 0 {
+		for i, column := range sqlArgColumns(query, len(args)) {
+			for _, c := range p.SQLColumns {
+				if strings.EqualFold(c, column) {
+					redacted[i] = p.Mask
+				}
+			}
+		}
+	}
+	return redacted
+}
+
+// sqlArgColumns guesses the column of each argument. "" if unknown.
+func sqlArgColumns(query string, n int) []string {
+	columns := make([]string, n)
+
+	var insertColumns []string
+	valuesAt := -1
+	if m := sqlInsertRegexp.FindStringSubmatchIndex(query); m != nil {
+		for _, c := range strings.Split(query[m[2]:m[3]], ",") {
+			insertColumns = append(insertColumns, unquoteSQLName(c))
+		}
+		valuesAt = m[1]
+	}
+
+	k := 0 // index of placeholders after VALUES
+	for i, m := range sqlPlaceholderRegexp.FindAllStringIndex(query, -1) {
+		arg := i
+		if query[m[0]] == '$' {
+			arg, _ = strconv.Atoi(query[m[0]+1 : m[1]])
+			arg--
+		}
+		if arg < 0 || arg >= n {
+			continue
+		}
+		if valuesAt >= 0 && m[0] >= valuesAt && len(insertColumns) > 0 {
+			columns[arg] = insertColumns[k%len(insertColumns)]
+			k++
+			continue
+		}
+		if c := sqlConditionRegexp.FindStringSubmatch(query[:m[0]]); c != nil {
+			columns[arg] = unquoteSQLName(c[1])
+		} else if c := sqlInRegexp.FindStringSubmatch(query[:m[0]]); c != nil {
+			columns[arg] = unquoteSQLName(c[1])
+		}
+	}
+	return columns
+}
+
+// unquoteSQLName returns "c" for "`t`.`c`".
+func unquoteSQLName(name string) string {
+	name = strings.TrimSpace(name)
+	name = name[strings.LastIndex(name, ".")+1:]
+	return strings.Trim(name, "`\"[]")
+}
@@ -0,0 +1,71 @@
+package u
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const requestBodyDumpKey = "requestBodyDump"
+
+// requestBodyDump is the head of request body, at most MaxLengthOfRequestDump bytes.
+type requestBodyDump struct {
+	body      []byte
+	truncated bool
+	err       error
+}
+
+// RequestBodyCapture returns a middleware which keeps the head of request body for logs of respondError and GinLogger.
+// GinMiddleware and GinLogger do the same, so use it only if neither of them is used.
+func RequestBodyCapture() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		captureRequestBody(c)
+		c.Next()
+	}
+}
+
+// captureRequestBody reads at most MaxLengthOfRequestDump bytes of the body, then puts them back in front of the rest,
+// so that handlers read the whole body as usual. It does nothing if the body has been captured.
+func captureRequestBody(c *gin.Context) {
+	if _, ok := c.Get(requestBodyDumpKey); ok {
+		return
+	}
+	if c.Request == nil || c.Request.Body == nil || c.Request.Body == http.NoBody {
+		c.Set(requestBodyDumpKey, &requestBodyDump{})
+		return
+	}
+
+	limit := MaxLengthOfRequestDump
+	if limit < 0 {
+		limit = 0
+	}
+	// Read one more byte to know if the body is truncated.
+	head, err := io.ReadAll(io.LimitReader(c.Request.Body, int64(limit)+1))
+	dump := &requestBodyDump{body: head, err: err}
+	if len(head) > limit {
+		dump.body = head[:limit]
+		dump.truncated = true
+	}
+	c.Set(requestBodyDumpKey, dump)
+
+	c.Request.Body = &prefixedReadCloser{
+		Reader: io.MultiReader(bytes.NewReader(head), c.Request.Body),
+		Closer: c.Request.Body,
+	}
+}
+
+type prefixedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func capturedRequestBody(c *gin.Context) *requestBodyDump {
+	if v, ok := c.Get(requestBodyDumpKey); ok {
+		if dump, ok := v.(*requestBodyDump); ok {
+			return dump
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package u
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"runtime"
@@ -8,6 +9,7 @@ import (
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
 )
 
 var SlowSQLDuration = time.Millisecond * 100
@@ -17,81 +19,119 @@ func NewDBXWithLogger(dbx *sqlx.DB, traceID string, file string) *DBXWithLogger
 	return &DBXWithLogger{DB: dbx, traceID: traceID, file: file}
 }
 
+// NewDBXWithCTX queries with ctx, so deadline and cancellation of ctx apply to queries.
+func NewDBXWithCTX(dbx *sqlx.DB, ctx *CTX, file string) *DBXWithLogger {
+	return &DBXWithLogger{DB: dbx, traceID: ctx.TraceID(), file: file, ctx: ctx}
+}
+
 type DBXWithLogger struct {
 	*sqlx.DB
 	traceID string
 	file    string
+	ctx     context.Context
+}
+
+func (o *DBXWithLogger) context() context.Context {
+	if o.ctx == nil {
+		return context.Background()
+	}
+	return o.ctx
 }
 
 func (o *DBXWithLogger) Query(query string, args ...interface{}) (*sql.Rows, error) {
 	begin := time.Now()
-	defer SQLTrace(o.traceID, o.file, begin, query, args...)
-	return o.DB.Query(query, args...)
+	defer traceSQL(o.context(), o.traceID, o.file, begin, query, args...)
+	return o.DB.QueryContext(o.context(), query, args...)
 }
 
 func (o *DBXWithLogger) Queryx(query string, args ...interface{}) (*sqlx.Rows, error) {
 	begin := time.Now()
-	defer SQLTrace(o.traceID, o.file, begin, query, args...)
-	return o.DB.Queryx(query, args...)
+	defer traceSQL(o.context(), o.traceID, o.file, begin, query, args...)
+	return o.DB.QueryxContext(o.context(), query, args...)
 }
 
 func (o *DBXWithLogger) QueryRowx(query string, args ...interface{}) *sqlx.Row {
 	begin := time.Now()
-	defer SQLTrace(o.traceID, o.file, begin, query, args...)
-	return o.DB.QueryRowx(query, args...)
+	defer traceSQL(o.context(), o.traceID, o.file, begin, query, args...)
+	return o.DB.QueryRowxContext(o.context(), query, args...)
 }
 
 func (o *DBXWithLogger) Exec(query string, args ...interface{}) (sql.Result, error) {
 	begin := time.Now()
-	defer SQLTrace(o.traceID, o.file, begin, query, args...)
-	return o.DB.Exec(query, args...)
+	defer traceSQL(o.context(), o.traceID, o.file, begin, query, args...)
+	return o.DB.ExecContext(o.context(), query, args...)
 }
 
 func NewTXXWithLogger(txx *sqlx.Tx, traceID string, file string) *TXXWithLogger {
 	return &TXXWithLogger{Tx: txx, traceID: traceID, file: file}
 }
 
+// NewTXXWithCTX queries with ctx, so deadline and cancellation of ctx apply to queries.
+func NewTXXWithCTX(txx *sqlx.Tx, ctx *CTX, file string) *TXXWithLogger {
+	return &TXXWithLogger{Tx: txx, traceID: ctx.TraceID(), file: file, ctx: ctx}
+}
+
 type TXXWithLogger struct {
 	*sqlx.Tx
 	traceID string
 	file    string
+	ctx     context.Context
+}
+
+func (o *TXXWithLogger) context() context.Context {
+	if o.ctx == nil {
+		return context.Background()
+	}
+	return o.ctx
 }
 
 func (o *TXXWithLogger) Query(query string, args ...interface{}) (*sql.Rows, error) {
 	begin := time.Now()
-	defer SQLTrace(o.traceID, o.file, begin, query, args...)
-	return o.Tx.Query(query, args...)
+	defer traceSQL(o.context(), o.traceID, o.file, begin, query, args...)
+	return o.Tx.QueryContext(o.context(), query, args...)
 }
 
 func (o *TXXWithLogger) Queryx(query string, args ...interface{}) (*sqlx.Rows, error) {
 	begin := time.Now()
-	defer SQLTrace(o.traceID, o.file, begin, query, args...)
-	return o.Tx.Queryx(query, args...)
+	defer traceSQL(o.context(), o.traceID, o.file, begin, query, args...)
+	return o.Tx.QueryxContext(o.context(), query, args...)
 }
 
 func (o *TXXWithLogger) QueryRowx(query string, args ...interface{}) *sqlx.Row {
 	begin := time.Now()
-	defer SQLTrace(o.traceID, o.file, begin, query, args...)
-	return o.Tx.QueryRowx(query, args...)
+	defer traceSQL(o.context(), o.traceID, o.file, begin, query, args...)
+	return o.Tx.QueryRowxContext(o.context(), query, args...)
 }
 
 func (o *TXXWithLogger) Exec(query string, args ...interface{}) (sql.Result, error) {
 	begin := time.Now()
-	defer SQLTrace(o.traceID, o.file, begin, query, args...)
-	return o.Tx.Exec(query, args...)
+	defer traceSQL(o.context(), o.traceID, o.file, begin, query, args...)
+	return o.Tx.ExecContext(o.context(), query, args...)
+}
+
+// traceSQL logs the query by SQLTrace and records a span if TracerProvider is set.
+func traceSQL(ctx context.Context, traceID, file string, begin time.Time, sql string, args ...interface{}) {
+	SQLTrace(traceID, file, begin, sql, args...)
+	sqlSpan(ctx, traceID, begin, file, sql)
 }
 
 func SQLTrace(traceID, file string, begin time.Time, sql string, args ...interface{}) {
 	elapsed := time.Since(begin)
-	slowTag := ""
+	observeSQL(file, elapsed)
+	fields := []zap.Field{
+		zap.String("tid", traceID),
+		zap.String("file", file),
+		zap.Float64("latency_ms", float64(elapsed.Nanoseconds())/1e6),
+		zap.String("sql", sql),
+		zap.Any("args", Redaction.RedactSQLArgs(sql, args)),
+	}
+	logger := SubsystemLogger(LogSubsystemSQL)
 	if elapsed >= VerySlowSQLDuration {
-		slowTag = " [VERY SLOW SQL]"
-		Warnf("[%s] %s\n[%vms]%s %v; args=%v", traceID, file, float64(elapsed.Nanoseconds())/1e6, slowTag, sql, args)
+		logger.Warn("VERY SLOW SQL", fields...)
+	} else if elapsed >= SlowSQLDuration {
+		logger.Debug("SLOW SQL", fields...)
 	} else {
-		if elapsed >= SlowSQLDuration {
-			slowTag = " [SLOW SQL]"
-		}
-		Debugf("[%s] %s\n[%vms]%s %v; args=%v", traceID, file, float64(elapsed.Nanoseconds())/1e6, slowTag, sql, args)
+		logger.Debug("SQL", fields...)
 	}
 }
 
@@ -0,0 +1,132 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/simplefelix/u"
+)
+
+// panickingConsumer panics on messages with value "bad".
+type panickingConsumer struct {
+	handled int64
+}
+
+func (c *panickingConsumer) LastOffset() int64 {
+	return -1
+}
+
+func (c *panickingConsumer) LastOffsetOfPartition(partition int32) int64 {
+	return -1
+}
+
+func (c *panickingConsumer) Handle(msg *sarama.ConsumerMessage) {
+	if string(msg.Value) == "bad" {
+		panic("bad message")
+	}
+	atomic.AddInt64(&c.handled, 1)
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %v", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestConsumerStopsPartitionOfUnhandledMessage(t *testing.T) {
+	const topic = "partition-stop-test"
+	sc := mocks.NewConsumer(t, nil)
+	sc.SetTopicMetadata(map[string][]int32{topic: {0, 1}})
+	p0 := sc.ExpectConsumePartition(topic, 0, 0)
+	p1 := sc.ExpectConsumePartition(topic, 1, 0)
+	p0.YieldMessage(&sarama.ConsumerMessage{Value: []byte("ok")})
+	p0.YieldMessage(&sarama.ConsumerMessage{Value: []byte("bad")})
+	p0.YieldMessage(&sarama.ConsumerMessage{Value: []byte("ok")})
+	p1.YieldMessage(&sarama.ConsumerMessage{Value: []byte("ok")})
+
+	handler := &panickingConsumer{}
+	consumer := u.NewConsumer(topic, sc)
+	consumer.Consumer = handler
+	if err := consumer.StartConsuming(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = consumer.CancelAndWait(context.Background()) }()
+
+	waitFor(t, "partition 0 to stop", func() bool {
+		s := consumer.Snapshot()
+		return len(s.Partitions) == 2 && s.Partitions[0].Stopped
+	})
+	p1.YieldMessage(&sarama.ConsumerMessage{Value: []byte("ok")})
+	waitFor(t, "partition 1 to go on", func() bool { return atomic.LoadInt64(&handler.handled) == 3 })
+
+	s := consumer.Snapshot()
+	if s.State != u.ConsumerStateConsuming {
+		t.Errorf("unexpected state %v", s.State)
+	}
+	if s.Partitions[0].Offset != 0 {
+		t.Errorf("expect the failed message not to be committed, got offset %d", s.Partitions[0].Offset)
+	}
+	if s.Partitions[1].Stopped || s.Partitions[1].Offset != 1 {
+		t.Errorf("unexpected partition 1 %+v", s.Partitions[1])
+	}
+	if !strings.Contains(s.LastError, "partition 0 stopped at offset 1") {
+		t.Errorf("unexpected last error %q", s.LastError)
+	}
+}
+
+func TestConsumerStaysReadyIfRestartFails(t *testing.T) {
+	const topic = "restart-failure-test"
+	sc := mocks.NewConsumer(t, nil)
+	sc.SetTopicMetadata(map[string][]int32{topic: {0}})
+	sc.ExpectConsumePartition(topic, 0, 0)
+
+	consumer := u.NewConsumer(topic, sc)
+	consumer.Consumer = &panickingConsumer{}
+	if err := consumer.StartConsuming(); err != nil {
+		t.Fatal(err)
+	}
+	// The mock refuses to consume a partition twice, as an unreachable broker would.
+	if err := consumer.StartOrRestart(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "restart to fail", func() bool {
+		return strings.Contains(consumer.Snapshot().LastError, "failed to restart")
+	})
+	if state := consumer.State(); state != u.ConsumerStateReady {
+		t.Errorf("unexpected state %v", state)
+	}
+	if u.ConsumerForTopic(topic) != &consumer {
+		t.Error("expect the consumer to stay registered")
+	}
+}
+
+func TestCancelledConsumerState(t *testing.T) {
+	const topic = "cancel-state-test"
+	sc := mocks.NewConsumer(t, nil)
+	sc.SetTopicMetadata(map[string][]int32{topic: {0}})
+	sc.ExpectConsumePartition(topic, 0, 0)
+
+	consumer := u.NewConsumer(topic, sc)
+	consumer.Consumer = &panickingConsumer{}
+	if state := consumer.State(); state != u.ConsumerStateReady {
+		t.Errorf("unexpected state %v before start", state)
+	}
+	if err := consumer.StartConsuming(); err != nil {
+		t.Fatal(err)
+	}
+	if err := consumer.CancelAndWait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if state := consumer.Snapshot().State; state != u.ConsumerStateCancelled {
+		t.Errorf("unexpected state %v after cancel", state)
+	}
+}
@@ -0,0 +1,62 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/simplefelix/u"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestCTXValuesThroughGRPCMetadata(t *testing.T) {
+	client := u.NewContext()
+	client.Set("userID", int64(1))
+	client.Set("Tenant.Name", "acme")
+	client.Set("not-sent", true)
+	out := client.AppendValuesToOutgoingContext(context.Background(), "userID", "Tenant.Name", "missing")
+
+	md, _ := metadata.FromOutgoingContext(out)
+	server := u.NewContext()
+	server.SetValuesFromIncomingContext(metadata.NewIncomingContext(context.Background(), md))
+
+	if v, ok := u.CTXValue[string](server, "userID"); !ok || v != "1" {
+		t.Errorf("userID: got %v, %v", v, ok)
+	}
+	if v, ok := u.CTXValue[string](server, "Tenant.Name"); !ok || v != "acme" {
+		t.Errorf("Tenant.Name: got %v, %v", v, ok)
+	}
+	if _, ok := u.CTXValue[int64](server, "userID"); ok {
+		t.Error("expect values to arrive as strings")
+	}
+	for _, key := range []string{"userid", "not-sent", "missing"} {
+		if v := server.Get(key); v != nil {
+			t.Errorf("%v: unexpected value %v", key, v)
+		}
+	}
+}
+
+func TestCTXSetAndGetConcurrently(t *testing.T) {
+	ctx := u.NewContext()
+	copied := ctx.WithContext(context.Background())
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := fmt.Sprintf("k%d", j%10)
+				ctx.Set(key, i)
+				_ = copied.Get(key)
+				_, _ = u.CTXValue[int](copied, key)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for j := 0; j < 10; j++ {
+		if _, ok := u.CTXValue[int](copied, fmt.Sprintf("k%d", j)); !ok {
+			t.Errorf("k%d is not shared with the copy", j)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/simplefelix/u"
+	"go.uber.org/zap"
+)
+
+func TestErrorLogSamplingFlushesSuppressedCount(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "error.log")
+	u.ReloadLoggerWithOutput(func(config *zap.Config, output *u.LogOutput) {
+		output.Sinks = []u.LogSink{{Filename: logFile, Encoding: "json"}}
+	})
+	defer u.ReloadLogger(nil)
+	u.SetErrorLogSampling(u.ErrorLogSampling{Interval: 50 * time.Millisecond, First: 1})
+	defer u.SetErrorLogSampling(u.ErrorLogSampling{})
+
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.Use(u.GinMiddleware())
+	r.GET("/", func(c *gin.Context) {
+		u.NewGinHelper(c).RespondError(u.ErrDBQueryError("timeout"))
+	})
+	for i := 0; i < 3; i++ {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+
+	// No more errors arrive. The summary is logged by the background flush.
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		_ = u.Logger.Sync()
+		content, _ := os.ReadFile(logFile)
+		if strings.Contains(string(content), `"suppressed":2`) {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Error("expect the number of suppressed logs to be logged after the interval")
+}
@@ -0,0 +1,209 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SimpleFelix/esg"
+	"github.com/gin-gonic/gin"
+	"github.com/simplefelix/u"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type writableError interface {
+	u.ErrorType
+	esg.ErrorTypeWriteable
+}
+
+// allErrorTypes returns a pointer to a value of each error type, since SetExtra needs pointer receivers.
+func allErrorTypes() []writableError {
+	err := errors.New("cause")
+	anyError := u.ErrAnyError(err)
+	cantStartConsumer := u.ErrCantStartConsumer(err)
+	conflict := u.ErrConflict(err)
+	consumerError := u.ErrConsumerError(err)
+	consumerNotFound := u.ErrConsumerNotFound(err)
+	dbExecutionError := u.ErrDBExecutionError(err)
+	dbQueryError := u.ErrDBQueryError(err)
+	failedToMarshalJSON := u.ErrFailedToMarshalJSON(err)
+	failedToReadRequestBody := u.ErrFailedToReadRequestBody(err)
+	failedToUnmarshalJSON := u.ErrFailedToUnmarshalJSON(err)
+	grpcDialErr := u.ErrGRPCDialErr("localhost", err)
+	internalError := u.ErrInternalError(err)
+	invalidJWT := u.ErrInvalidJWT(err)
+	invalidLogLevel := u.ErrInvalidLogLevel(err)
+	mongoQueryErr := u.ErrMongoQueryErr(err)
+	mongoWriteErr := u.ErrMongoWriteErr(err)
+	paramBindingErr := u.ErrParamBindingErr(err)
+	shortUUIDLenConstraint := u.ErrShortUUIDLenConstraint()
+	grpcRemoteError := u.ErrGRPCRemoteError(status.New(codes.Internal, "cause"))
+	return []writableError{
+		&anyError, &cantStartConsumer, &conflict, &consumerError, &consumerNotFound, &dbExecutionError,
+		&dbQueryError, &failedToMarshalJSON, &failedToReadRequestBody, &failedToUnmarshalJSON, &grpcDialErr,
+		&internalError, &invalidJWT, &invalidLogLevel, &mongoQueryErr, &mongoWriteErr, &paramBindingErr,
+		&shortUUIDLenConstraint, &grpcRemoteError,
+	}
+}
+
+func TestErrorModifiersOnAllErrorTypes(t *testing.T) {
+	mods := map[u.ErrorFlag]func(esg.ErrorTypeWriteable){
+		u.ErrFlagNoNeedToLog:   u.ErrModNoNeedToLog,
+		u.ErrFlagPrintAsInfo:   u.ErrModPrintAsInfo,
+		u.ErrFlagRetryable:     u.ErrModRetryable,
+		u.ErrFlagExposeDetails: u.ErrModExposeDetails,
+	}
+	for flag, mod := range mods {
+		for _, erro := range allErrorTypes() {
+			mod(erro)
+			if !u.HasErrorFlag(erro, flag) {
+				t.Errorf("flag %v is not set on %T", flag, erro)
+			}
+		}
+	}
+}
+
+func TestErrorFlagsAndFieldsAccumulate(t *testing.T) {
+	for _, erro := range allErrorTypes() {
+		u.ErrModNoNeedToLog(erro)
+		u.SetErrorField(erro, "user_id", 42)
+		u.ErrModRetryable(erro)
+		u.SetErrorField(erro, "order_id", "o-1")
+
+		meta := u.ErrorMetaOf(erro)
+		if meta.Flags != u.ErrFlagNoNeedToLog|u.ErrFlagRetryable {
+			t.Errorf("unexpected flags %b of %T", meta.Flags, erro)
+		}
+		if meta.Fields["user_id"] != 42 || meta.Fields["order_id"] != "o-1" {
+			t.Errorf("unexpected fields %v of %T", meta.Fields, erro)
+		}
+	}
+}
+
+func TestErrorModifierDoesNotChangeCopies(t *testing.T) {
+	erro := u.ErrConflict("dup")
+	u.ErrModRetryable(&erro)
+	copied := erro
+	u.ErrModExposeDetails(&erro)
+	if u.HasErrorFlag(copied, u.ErrFlagExposeDetails) {
+		t.Error("modifying an error changed its copy")
+	}
+	if !u.HasErrorFlag(copied, u.ErrFlagRetryable) {
+		t.Error("copy lost flags set before copying")
+	}
+}
+
+func TestIsRetryableErrorInChain(t *testing.T) {
+	cause := u.ErrDBQueryError("timeout")
+	u.ErrModRetryable(&cause)
+	if !u.IsRetryableError(u.ErrAnyError(cause)) {
+		t.Error("expect retryable cause to make the chain retryable")
+	}
+	if u.IsRetryableError(u.ErrAnyError("timeout")) {
+		t.Error("expect error without flag not to be retryable")
+	}
+}
+
+// respondErrorWithLog responds erro and returns the error payload and lines logged by respondError.
+func respondErrorWithLog(t *testing.T, erro u.ErrorType) (u.ErrorPayload, []map[string]interface{}) {
+	logFile := filepath.Join(t.TempDir(), "error.log")
+	u.ReloadLoggerWithOutput(func(config *zap.Config, output *u.LogOutput) {
+		output.Sinks = []u.LogSink{{Filename: logFile, Encoding: "json"}}
+	})
+	defer u.ReloadLogger(nil)
+
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.Use(u.GinMiddleware())
+	r.GET("/", func(c *gin.Context) {
+		u.NewGinHelper(c).RespondError(erro)
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	_ = u.Logger.Sync()
+
+	var body struct {
+		Error u.ErrorPayload `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to unmarshal response %q: %v", w.Body.String(), err)
+	}
+
+	content, _ := os.ReadFile(logFile)
+	var logs []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("failed to unmarshal log %q: %v", line, err)
+		}
+		if entry["tid"] == body.Error.TID {
+			logs = append(logs, entry)
+		}
+	}
+	return body.Error, logs
+}
+
+func TestRespondErrorNoNeedToLog(t *testing.T) {
+	erro := u.ErrInternalError("expected failure")
+	u.ErrModNoNeedToLog(&erro)
+	_, logs := respondErrorWithLog(t, erro)
+	if len(logs) != 0 {
+		t.Errorf("expect no log, got %v", logs)
+	}
+}
+
+func TestRespondErrorPrintAsInfo(t *testing.T) {
+	erro := u.ErrInternalError("known failure")
+	u.ErrModPrintAsInfo(&erro)
+	_, logs := respondErrorWithLog(t, erro)
+	if len(logs) != 1 || logs[0]["level"] != "INFO" {
+		t.Errorf("expect 1 info log, got %v", logs)
+	}
+}
+
+func TestRespondErrorRetryable(t *testing.T) {
+	erro := u.ErrInternalError("busy")
+	u.ErrModRetryable(&erro)
+	payload, logs := respondErrorWithLog(t, erro)
+	if !payload.Retryable {
+		t.Error("expect retryable in response")
+	}
+	if len(logs) != 1 || logs[0]["level"] != "ERROR" {
+		t.Errorf("expect 1 error log, got %v", logs)
+	}
+}
+
+func TestRespondErrorExposeDetails(t *testing.T) {
+	erro := u.ErrInternalError(errors.New("disk full"))
+	u.SetErrorField(&erro, "volume", "data")
+	u.ErrModExposeDetails(&erro)
+	payload, logs := respondErrorWithLog(t, erro)
+	if payload.Details["volume"] != "data" {
+		t.Errorf("expect custom field in details, got %v", payload.Details)
+	}
+	if _, ok := payload.Details["causes"]; !ok {
+		t.Errorf("expect causes in details, got %v", payload.Details)
+	}
+	if len(logs) != 1 || logs[0]["volume"] != "data" {
+		t.Errorf("expect custom field in log, got %v", logs)
+	}
+}
+
+func TestRespondErrorHidesDetailsByDefault(t *testing.T) {
+	erro := u.ErrInternalError(errors.New("disk full"))
+	u.SetErrorField(&erro, "volume", "data")
+	payload, _ := respondErrorWithLog(t, erro)
+	if payload.Details != nil || payload.Retryable {
+		t.Errorf("expect no details, got %+v", payload)
+	}
+}
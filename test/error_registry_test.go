@@ -0,0 +1,91 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/simplefelix/u"
+	"google.golang.org/grpc/codes"
+)
+
+func TestErrorRegistryMatchesErrorTypes(t *testing.T) {
+	for _, erro := range allErrorTypes() {
+		switch erro.(type) {
+		case *u.AnyError, *u.GRPCRemoteError:
+			continue
+		}
+		def, ok := u.LookupError(erro.ErrorCode())
+		if !ok {
+			t.Errorf("%T is not registered", erro)
+			continue
+		}
+		if def.StatusCode != erro.StatusCode() {
+			t.Errorf("%T: registered status %d, StatusCode() %d", erro, def.StatusCode, erro.StatusCode())
+		}
+		if erro.StatusCode() < 500 && def.GRPCCode == codes.Internal {
+			t.Errorf("%T: client error %d is registered as gRPC %v", erro, erro.StatusCode(), def.GRPCCode)
+		}
+	}
+}
+
+func TestLocalizedDesc(t *testing.T) {
+	code := "LocalizedDescTest"
+	u.RegisterError(u.ErrorDefinition{
+		Code:       code,
+		StatusCode: 400,
+		GRPCCode:   codes.InvalidArgument,
+		Messages: map[string]string{
+			"en":    "Bad request {{.TID}}",
+			"zh-TW": "請求錯誤",
+			"zh-CN": "请求错误 {{.Error}}",
+			"fr":    "{{.Missing.Field}}",
+		},
+	})
+	erro := u.NewAnyError(code, "cause", 400)
+	tests := []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{"", "cause"},
+		{"en", "Bad request t1"},
+		{"EN-us", "Bad request t1"},
+		{"zh-TW", "請求錯誤"},
+		{"zh_cn", "请求错误 cause"},
+		{"zh", "请求错误 cause"},
+		{"zh-HK", "请求错误 cause"},
+		{"de, zh-TW;q=0.5, en;q=0.8", "Bad request t1"},
+		{"en;q=0.1, zh-TW;q=0.9", "請求錯誤"},
+		{"en;q=0, zh-TW;q=0.1", "請求錯誤"},
+		{"en;q=0", "cause"},
+		{"*", "cause"},
+		{"de, *;q=0.5", "cause"},
+		{"en;q=bad, zh-TW;q=0.9", "Bad request t1"},
+		{" , ;q=1, en", "Bad request t1"},
+		{"fr", "cause"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%q", tt.acceptLanguage), func(t *testing.T) {
+			if got := u.LocalizedDesc(erro, tt.acceptLanguage, "t1"); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := u.LocalizedDesc(u.NewAnyError("NotRegistered", "cause", 400), "en", "t1"); got != "cause" {
+		t.Errorf("not registered: got %q", got)
+	}
+}
+
+func TestSetErrorMessage(t *testing.T) {
+	code := "SetErrorMessageTest"
+	if u.SetErrorMessage("NeverRegistered", "en", "x") {
+		t.Error("expect false for unregistered code")
+	}
+	u.RegisterError(u.ErrorDefinition{Code: code, StatusCode: 409, GRPCCode: codes.AlreadyExists})
+	if !u.SetErrorMessage(code, "en", "Already exists") {
+		t.Fatal("expect true for registered code")
+	}
+	if got := u.LocalizedDesc(u.NewAnyError(code, "dup", 409), "en-GB", ""); got != "Already exists" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,39 @@
+package test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/simplefelix/u"
+)
+
+func TestLoggerKeepsQuery(t *testing.T) {
+	var out bytes.Buffer
+	var param u.LogFormatterParams
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.Use(
+		u.LoggerWithConfig(u.LoggerConfig{Output: &out, AccessLog: true}),
+		u.LoggerWithConfig(u.LoggerConfig{AccessLog: true, Formatter: func(p u.LogFormatterParams) string {
+			param = p
+			return ""
+		}}),
+	)
+	r.GET("/users/:id", func(c *gin.Context) {})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/42?fields=name", nil))
+
+	if !strings.Contains(out.String(), `"/users/42?fields=name"`) {
+		t.Errorf("expect path with query in %q", out.String())
+	}
+	if param.Route != "/users/:id" || param.Path != "/users/42" || param.RawQuery != "fields=name" {
+		t.Errorf("unexpected route %q, path %q and query %q", param.Route, param.Path, param.RawQuery)
+	}
+	if got := param.RequestURI(); got != "/users/42?fields=name" {
+		t.Errorf("unexpected request URI %q", got)
+	}
+}
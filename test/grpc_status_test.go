@@ -0,0 +1,100 @@
+package test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/simplefelix/u"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type erroringHealthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+	check func() (*grpc_health_v1.HealthCheckResponse, error)
+}
+
+func (s *erroringHealthServer) Check(context.Context, *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	return s.check()
+}
+
+// checkHealth calls a server which handles Check by check, through the error interceptors.
+func checkHealth(t *testing.T, check func() (*grpc_health_v1.HealthCheckResponse, error)) error {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(grpc.UnaryInterceptor(u.GRPCErrorUnaryServerInterceptor))
+	grpc_health_v1.RegisterHealthServer(server, &erroringHealthServer{check: check})
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(u.GRPCErrorUnaryClientInterceptor),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "tid", "trace-of-client")
+	_, err = grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	return err
+}
+
+func TestGRPCErrorTypeRoundTrip(t *testing.T) {
+	err := checkHealth(t, func() (*grpc_health_v1.HealthCheckResponse, error) {
+		erro := u.ErrConsumerNotFound("no consumer of topic t")
+		u.ErrModRetryable(&erro)
+		return nil, erro
+	})
+
+	erro, ok := err.(u.GRPCRemoteError)
+	if !ok {
+		t.Fatalf("expect GRPCRemoteError, got %T %v", err, err)
+	}
+	if erro.ErrorCode() != "ConsumerNotFound" || erro.StatusCode() != 404 {
+		t.Errorf("unexpected code %v and status %v", erro.ErrorCode(), erro.StatusCode())
+	}
+	if erro.Error() != "no consumer of topic t" {
+		t.Errorf("unexpected message %q", erro.Error())
+	}
+	if erro.RemoteTraceID() != "trace-of-client" {
+		t.Errorf("unexpected trace ID %q", erro.RemoteTraceID())
+	}
+	if !u.HasErrorFlag(erro, u.ErrFlagRetryable) {
+		t.Error("expect retryable")
+	}
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("unexpected gRPC code %v", status.Code(err))
+	}
+}
+
+func TestGRPCPanickedErrorType(t *testing.T) {
+	err := checkHealth(t, func() (*grpc_health_v1.HealthCheckResponse, error) {
+		panic(u.ErrParamBindingErr("bad request"))
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("unexpected gRPC code %v", status.Code(err))
+	}
+	if erro, ok := err.(u.ErrorType); !ok || erro.ErrorCode() != "ParamBindErr" || erro.StatusCode() != 400 {
+		t.Errorf("unexpected error %T %v", err, err)
+	}
+}
+
+func TestGRPCPlainStatusError(t *testing.T) {
+	err := checkHealth(t, func() (*grpc_health_v1.HealthCheckResponse, error) {
+		return nil, status.Error(codes.Unavailable, "maintenance")
+	})
+	erro, ok := err.(u.ErrorType)
+	if !ok {
+		t.Fatalf("expect ErrorType, got %T", err)
+	}
+	if erro.ErrorCode() != "Unavailable" || erro.StatusCode() != 503 {
+		t.Errorf("unexpected code %v and status %v", erro.ErrorCode(), erro.StatusCode())
+	}
+}
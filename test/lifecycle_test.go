@@ -0,0 +1,38 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/simplefelix/u"
+)
+
+// blockingCloser blocks in Close until release is closed, like an AsyncProducer flushing to an unreachable broker.
+type blockingCloser struct {
+	release chan struct{}
+}
+
+func (c *blockingCloser) Close() error {
+	<-c.release
+	return nil
+}
+
+func TestShutdownStopsWaitingAtDeadline(t *testing.T) {
+	producer := &blockingCloser{release: make(chan struct{})}
+	defer close(producer.release)
+	l := u.NewLifecycle()
+	l.RegisterKafkaProducer(producer)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	begin := time.Now()
+	err := l.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expect deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Errorf("Shutdown took %v", elapsed)
+	}
+}
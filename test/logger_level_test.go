@@ -0,0 +1,37 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/simplefelix/u"
+)
+
+func TestChangeLogLevel(t *testing.T) {
+	defer u.ResetSubsystemLogLevel(u.LogSubsystemSQL)
+	tests := []struct {
+		subsystem string
+		level     string
+		wantErr   bool
+	}{
+		{u.LogSubsystemSQL, "warn", false},
+		{u.LogSubsystemSQL, "verbose", true},
+		{"sqll", "debug", true},
+		{"unknown", "info", true},
+	}
+	for _, tt := range tests {
+		erro := u.ChangeLogLevel(tt.subsystem, tt.level)
+		if (erro != nil) != tt.wantErr {
+			t.Errorf("%q %q: got %v", tt.subsystem, tt.level, erro)
+		}
+		if erro != nil && erro.ErrorCode() != "InvalidLogLevel" {
+			t.Errorf("%q %q: unexpected code %v", tt.subsystem, tt.level, erro.ErrorCode())
+		}
+	}
+	levels := u.LogLevels()
+	if levels[u.LogSubsystemSQL] != "warn" {
+		t.Errorf("unexpected level of sql %v", levels)
+	}
+	if _, ok := levels["sqll"]; ok {
+		t.Errorf("unknown subsystem is set %v", levels)
+	}
+}
@@ -2,7 +2,8 @@ package test
 
 import (
 	"testing"
-	"u"
+
+	"github.com/simplefelix/u"
 )
 
 func TestShortUUID(t *testing.T) {
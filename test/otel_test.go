@@ -0,0 +1,113 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/simplefelix/u"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestGinMiddlewareServerSpan(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	u.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer func() { u.TracerProvider = nil }()
+
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.Use(u.GinMiddleware())
+	var traceID, spanID string
+	r.GET("/users/:id", func(c *gin.Context) {
+		h := u.NewGinHelper(c)
+		traceID = h.CTX().TraceID()
+		spanID = h.CTX().SpanID()
+		h.Respond(200, nil)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expect 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "GET /users/:id" {
+		t.Errorf("unexpected span name %v", span.Name)
+	}
+	if got := span.SpanContext.TraceID().String(); got != traceID || got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace ID of span %v, trace ID of CTX %v", got, traceID)
+	}
+	if got := span.Parent.SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("unexpected parent span ID %v", got)
+	}
+	if got := span.SpanContext.SpanID().String(); got != spanID {
+		t.Errorf("span ID of span %v, span ID of CTX %v", got, spanID)
+	}
+}
+
+func TestGinMiddlewareRootSpanSharesTraceID(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	u.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter), sdktrace.WithIDGenerator(u.CTXIDGenerator{}))
+	defer func() { u.TracerProvider = nil }()
+
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.Use(u.GinMiddleware())
+	var traceID string
+	r.GET("/", func(c *gin.Context) {
+		traceID = u.NewGinHelper(c).CTX().TraceID()
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expect 1 span, got %d", len(spans))
+	}
+	if got := spans[0].SpanContext.TraceID().String(); got != traceID {
+		t.Errorf("trace ID of span %v, trace ID of CTX %v", got, traceID)
+	}
+	if got := w.Header().Get(u.TraceIDHeader); got != traceID {
+		t.Errorf("trace ID in response header %v, trace ID of CTX %v", got, traceID)
+	}
+}
+
+func TestGinMiddlewareKeepsTraceIDOfCTX(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	u.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer func() { u.TracerProvider = nil }()
+
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.Use(u.GinLogger(), u.GinMiddleware())
+	var traceID string
+	r.GET("/", func(c *gin.Context) {
+		traceID = u.NewGinHelper(c).CTX().TraceID()
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := w.Header().Get(u.TraceIDHeader); got != traceID {
+		t.Errorf("trace ID in response header %v, trace ID of CTX %v", got, traceID)
+	}
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expect 1 span, got %d", len(spans))
+	}
+	var tid string
+	for _, attr := range spans[0].Attributes {
+		if attr.Key == "tid" {
+			tid = attr.Value.AsString()
+		}
+	}
+	if tid != traceID {
+		t.Errorf("attribute tid of span %v, trace ID of CTX %v", tid, traceID)
+	}
+}
@@ -0,0 +1,154 @@
+package test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/simplefelix/u"
+)
+
+func TestRedactJSON(t *testing.T) {
+	policy := u.DefaultRedactionPolicy()
+	policy.JSONFields = []string{"password", "card.number", "items.*.token"}
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "field at any depth",
+			body: `{"user":{"name":"a","password":"secret"},"password":"p"}`,
+			want: `{"password":"******","user":{"name":"a","password":"******"}}`,
+		},
+		{
+			name: "dotted path from root",
+			body: `{"card":{"number":"4111","cvc":"1"},"other":{"number":"1"}}`,
+			want: `{"card":{"cvc":"1","number":"******"},"other":{"number":"1"}}`,
+		},
+		{
+			name: "wildcard in array",
+			body: `{"items":[{"token":"t1"},{"token":"t2","id":1}]}`,
+			want: `{"items":[{"token":"******"},{"id":1,"token":"******"}]}`,
+		},
+		{
+			name: "large numbers keep precision",
+			body: `{"id":12345678901234567890,"amount":1.10,"password":"p"}`,
+			want: `{"amount":1.10,"id":12345678901234567890,"password":"******"}`,
+		},
+		{
+			name: "truncated JSON",
+			body: `{"user":"a","password":"sec`,
+			want: `{"user":"a","password":"******"`,
+		},
+		{
+			name: "truncated JSON with escaped quote",
+			body: `{"password" : "a\"b", "card": {"number": "4111", "cvc`,
+			want: `{"password" : "******", "card": {"number": "******", "cvc`,
+		},
+		{
+			name: "multiple JSON values",
+			body: `{"password":"a"} {"password":"b"}`,
+			want: `{"password":"******"} {"password":"******"}`,
+		},
+		{
+			name: "not JSON",
+			body: `password=secret`,
+			want: `password=secret`,
+		},
+		{
+			name: "empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.RedactJSON([]byte(tt.body)); got != tt.want {
+				t.Errorf("got %s; want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactSQLArgs(t *testing.T) {
+	policy := u.RedactionPolicy{Mask: "******", SQLColumns: []string{"password", "token"}}
+	tests := []struct {
+		name  string
+		query string
+		args  []interface{}
+		want  []interface{}
+	}{
+		{
+			name:  "question mark condition",
+			query: "SELECT id FROM users WHERE name = ? AND `users`.`password` = ?",
+			args:  []interface{}{"a", "secret"},
+			want:  []interface{}{"a", "******"},
+		},
+		{
+			name:  "dollar placeholders out of order",
+			query: `UPDATE users SET "password" = $2 WHERE id = $1`,
+			args:  []interface{}{1, "secret"},
+			want:  []interface{}{1, "******"},
+		},
+		{
+			name:  "like",
+			query: "SELECT id FROM users WHERE token LIKE ?",
+			args:  []interface{}{"t%"},
+			want:  []interface{}{"******"},
+		},
+		{
+			name:  "in list",
+			query: "SELECT id FROM users WHERE password IN (?, ?) AND name NOT IN (?)",
+			args:  []interface{}{"s1", "s2", "a"},
+			want:  []interface{}{"******", "******", "a"},
+		},
+		{
+			name:  "in list with dollar placeholders",
+			query: "SELECT id FROM users WHERE id = $1 AND token not in ($2,$3)",
+			args:  []interface{}{1, "t1", "t2"},
+			want:  []interface{}{1, "******", "******"},
+		},
+		{
+			name:  "insert",
+			query: "INSERT INTO users (name, `password`) VALUES (?, ?)",
+			args:  []interface{}{"a", "secret"},
+			want:  []interface{}{"a", "******"},
+		},
+		{
+			name:  "multi-row insert",
+			query: "INSERT IGNORE INTO users (name, password) VALUES (?, ?), (?, ?)",
+			args:  []interface{}{"a", "s1", "b", "s2"},
+			want:  []interface{}{"a", "******", "b", "******"},
+		},
+		{
+			name:  "unknown columns",
+			query: "SELECT id FROM users LIMIT ?",
+			args:  []interface{}{10},
+			want:  []interface{}{10},
+		},
+		{
+			name:  "more placeholders than args",
+			query: "SELECT id FROM users WHERE password = ? AND name = ?",
+			args:  []interface{}{"secret"},
+			want:  []interface{}{"******"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := policy.RedactSQLArgs(tt.query, tt.args)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v; want %v", got, tt.want)
+			}
+			if len(tt.args) > 0 && &got[0] == &tt.args[0] {
+				t.Error("expect a copy of args")
+			}
+		})
+	}
+}
+
+func TestRedactSQLArgPositions(t *testing.T) {
+	policy := u.RedactionPolicy{Mask: "******", SQLArgPositions: []int{1, 5, -1}}
+	args := []interface{}{"a", "b"}
+	got := policy.RedactSQLArgs("CALL p(?, ?)", args)
+	if !reflect.DeepEqual(got, []interface{}{"a", "******"}) || args[1] != "b" {
+		t.Errorf("got %v, args %v", got, args)
+	}
+}
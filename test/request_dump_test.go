@@ -0,0 +1,69 @@
+package test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/simplefelix/u"
+	"go.uber.org/zap"
+)
+
+func TestRequestBodyCapture(t *testing.T) {
+	defer func(limit int) { u.MaxLengthOfRequestDump = limit }(u.MaxLengthOfRequestDump)
+	u.MaxLengthOfRequestDump = 16
+
+	tests := []struct {
+		name        string
+		body        string
+		wantDump    string
+		wantMarkers int
+	}{
+		{"short", "0123456789", "0123456789", 0},
+		{"exactly the limit", "0123456789abcdef", "0123456789abcdef", 0},
+		{"long", "0123456789abcdefTAIL" + strings.Repeat("x", 100), "0123456789abcdef", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logFile := filepath.Join(t.TempDir(), "error.log")
+			u.ReloadLoggerWithOutput(func(config *zap.Config, output *u.LogOutput) {
+				output.Sinks = []u.LogSink{{Filename: logFile, Encoding: "json"}}
+			})
+			defer u.ReloadLogger(nil)
+
+			gin.SetMode(gin.ReleaseMode)
+			r := gin.New()
+			r.Use(u.GinMiddleware())
+			var read []byte
+			r.POST("/", func(c *gin.Context) {
+				read, _ = io.ReadAll(c.Request.Body)
+				u.NewGinHelper(c).RespondError(u.ErrInternalError("failed"))
+			})
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body)))
+			_ = u.Logger.Sync()
+
+			if string(read) != tt.body {
+				t.Errorf("handler read %q; want the whole body", read)
+			}
+			content, _ := os.ReadFile(logFile)
+			var entry struct {
+				Request string `json:"request"`
+			}
+			if err := json.Unmarshal(content, &entry); err != nil {
+				t.Fatalf("failed to unmarshal log %q: %v", content, err)
+			}
+			if !strings.Contains(entry.Request, tt.wantDump) || strings.Contains(entry.Request, "TAIL") {
+				t.Errorf("unexpected dump %q", entry.Request)
+			}
+			if got := strings.Count(entry.Request, "...(truncated)"); got != tt.wantMarkers {
+				t.Errorf("got %d truncation markers in %q", got, entry.Request)
+			}
+		})
+	}
+}
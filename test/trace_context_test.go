@@ -0,0 +1,298 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/simplefelix/u"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	testTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testSpanID  = "00f067aa0ba902b7"
+)
+
+func TestTraceContextFromHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    u.TraceContext
+		wantOK  bool
+	}{
+		{
+			name:    "traceparent",
+			headers: map[string]string{"traceparent": "00-" + testTraceID + "-" + testSpanID + "-01", "tracestate": "k=v"},
+			want:    u.TraceContext{TraceID: testTraceID, SpanID: testSpanID, TraceState: "k=v"},
+			wantOK:  true,
+		},
+		{
+			name:    "traceparent not sampled",
+			headers: map[string]string{"traceparent": "00-" + testTraceID + "-" + testSpanID + "-00"},
+			want:    u.TraceContext{TraceID: testTraceID, SpanID: testSpanID, NotSampled: true},
+			wantOK:  true,
+		},
+		{
+			name:    "traceparent of future version with extra fields",
+			headers: map[string]string{"traceparent": "01-" + testTraceID + "-" + testSpanID + "-01-future"},
+			want:    u.TraceContext{TraceID: testTraceID, SpanID: testSpanID},
+			wantOK:  true,
+		},
+		{
+			name:    "traceparent version 00 with extra fields",
+			headers: map[string]string{"traceparent": "00-" + testTraceID + "-" + testSpanID + "-01-future"},
+		},
+		{
+			name:    "traceparent version ff",
+			headers: map[string]string{"traceparent": "ff-" + testTraceID + "-" + testSpanID + "-01"},
+		},
+		{
+			name:    "traceparent upper case trace ID",
+			headers: map[string]string{"traceparent": "00-" + strings.ToUpper(testTraceID) + "-" + testSpanID + "-01"},
+		},
+		{
+			name:    "traceparent all-zero trace ID",
+			headers: map[string]string{"traceparent": "00-" + strings.Repeat("0", 32) + "-" + testSpanID + "-01"},
+		},
+		{
+			name:    "traceparent all-zero span ID",
+			headers: map[string]string{"traceparent": "00-" + testTraceID + "-" + strings.Repeat("0", 16) + "-01"},
+		},
+		{
+			name:    "traceparent short trace ID",
+			headers: map[string]string{"traceparent": "00-" + testTraceID[:30] + "-" + testSpanID + "-01"},
+		},
+		{
+			name:    "b3 single header",
+			headers: map[string]string{"b3": testTraceID + "-" + testSpanID + "-1"},
+			want:    u.TraceContext{TraceID: testTraceID, SpanID: testSpanID},
+			wantOK:  true,
+		},
+		{
+			name:    "b3 single header 64-bit trace ID not sampled",
+			headers: map[string]string{"b3": "a3ce929d0e0e4736-" + testSpanID + "-0"},
+			want:    u.TraceContext{TraceID: "a3ce929d0e0e4736", SpanID: testSpanID, NotSampled: true},
+			wantOK:  true,
+		},
+		{
+			name:    "b3 sampling state only",
+			headers: map[string]string{"b3": "0"},
+		},
+		{
+			name:    "b3 upper case",
+			headers: map[string]string{"b3": strings.ToUpper(testTraceID) + "-" + testSpanID},
+		},
+		{
+			name:    "b3 multiple headers 64-bit trace ID",
+			headers: map[string]string{"x-b3-traceid": "a3ce929d0e0e4736", "x-b3-spanid": testSpanID, "x-b3-sampled": "0"},
+			want:    u.TraceContext{TraceID: "a3ce929d0e0e4736", SpanID: testSpanID, NotSampled: true},
+			wantOK:  true,
+		},
+		{
+			name:    "b3 multiple headers invalid span ID",
+			headers: map[string]string{"x-b3-traceid": testTraceID, "x-b3-spanid": "bad span"},
+			want:    u.TraceContext{TraceID: testTraceID},
+			wantOK:  true,
+		},
+		{
+			name:    "legacy tid",
+			headers: map[string]string{"tid": "legacy-trace-id"},
+			want:    u.TraceContext{TraceID: "legacy-trace-id"},
+			wantOK:  true,
+		},
+		{
+			name:    "legacy tid with line break",
+			headers: map[string]string{"tid": "evil\nINJECT"},
+		},
+		{
+			name:    "legacy tid too long",
+			headers: map[string]string{"tid": strings.Repeat("a", 129)},
+		},
+		{
+			name:    "legacy tid padded in traceparent",
+			headers: map[string]string{"traceparent": "00-00000000000000000000abc123def456-" + testSpanID + "-01", "tid": "abc123def456"},
+			want:    u.TraceContext{TraceID: "abc123def456", SpanID: testSpanID},
+			wantOK:  true,
+		},
+		{
+			name:    "legacy tid padded in b3",
+			headers: map[string]string{"b3": "0000000000000000a3ce929d0e0e4736-" + testSpanID + "-1", "tid": "a3ce929d0e0e4736"},
+			want:    u.TraceContext{TraceID: "a3ce929d0e0e4736", SpanID: testSpanID},
+			wantOK:  true,
+		},
+		{
+			name: "traceparent takes precedence",
+			headers: map[string]string{
+				"traceparent":  "00-" + testTraceID + "-" + testSpanID + "-01",
+				"b3":           "a3ce929d0e0e4736-b7ad6b7169203331-1",
+				"x-b3-traceid": "a3ce929d0e0e4737",
+				"tid":          "legacy",
+			},
+			want:   u.TraceContext{TraceID: testTraceID, SpanID: testSpanID},
+			wantOK: true,
+		},
+		{
+			name: "invalid traceparent falls back to b3",
+			headers: map[string]string{
+				"traceparent": "ff-" + testTraceID + "-" + testSpanID + "-01",
+				"b3":          "a3ce929d0e0e4736-b7ad6b7169203331-1",
+				"tid":         "legacy",
+			},
+			want:   u.TraceContext{TraceID: "a3ce929d0e0e4736", SpanID: "b7ad6b7169203331"},
+			wantOK: true,
+		},
+		{
+			name:    "b3 single header takes precedence over multiple headers",
+			headers: map[string]string{"b3": "a3ce929d0e0e4736-b7ad6b7169203331", "x-b3-traceid": "a3ce929d0e0e4737", "tid": "legacy"},
+			want:    u.TraceContext{TraceID: "a3ce929d0e0e4736", SpanID: "b7ad6b7169203331"},
+			wantOK:  true,
+		},
+		{
+			name:    "b3 multiple headers take precedence over legacy tid",
+			headers: map[string]string{"x-b3-traceid": "a3ce929d0e0e4737", "tid": "legacy"},
+			want:    u.TraceContext{TraceID: "a3ce929d0e0e4737"},
+			wantOK:  true,
+		},
+		{
+			name: "none",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			md := metadata.MD{}
+			for k, v := range tt.headers {
+				header.Set(k, v)
+				md.Set(k, v)
+			}
+			for source, got := range map[string]func() (u.TraceContext, bool){
+				"header":   func() (u.TraceContext, bool) { return u.TraceContextFromHeader(header) },
+				"metadata": func() (u.TraceContext, bool) { return u.TraceContextFromMD(md) },
+			} {
+				tc, ok := got()
+				if ok != tt.wantOK || tc != tt.want {
+					t.Errorf("%s: got %+v, %v; want %+v, %v", source, tc, ok, tt.want, tt.wantOK)
+				}
+			}
+		})
+	}
+}
+
+func TestTraceHeaders(t *testing.T) {
+	md := metadata.Pairs("traceparent", "00-"+testTraceID+"-"+testSpanID+"-00", "tracestate", "k=v")
+	ctx := u.NewCTXWithGRPCContext(metadata.NewIncomingContext(context.Background(), md))
+
+	out, _ := metadata.FromOutgoingContext(ctx.FillGRPCContext(context.Background()))
+	spanID := ctx.SpanID()
+	if spanID == testSpanID {
+		t.Fatal("expect a span ID of this service, got the one of the caller")
+	}
+	want := map[string]string{
+		"tid":         testTraceID,
+		"traceparent": "00-" + testTraceID + "-" + spanID + "-00",
+		"b3":          testTraceID + "-" + spanID + "-0",
+		"tracestate":  "k=v",
+	}
+	for k, v := range want {
+		if got := out.Get(k); len(got) != 1 || got[0] != v {
+			t.Errorf("%s: got %v; want %v", k, got, v)
+		}
+	}
+}
+
+func TestTraceHeadersOf64BitTraceID(t *testing.T) {
+	header := http.Header{}
+	u.InjectTraceHeaders(u.NewCTXWithTraceID("a3ce929d0e0e4736"), header)
+	if got := header.Get("tid"); got != "a3ce929d0e0e4736" {
+		t.Errorf("tid: got %v", got)
+	}
+	traceparent := header.Get("traceparent")
+	if !strings.HasPrefix(traceparent, "00-0000000000000000a3ce929d0e0e4736-") || !strings.HasSuffix(traceparent, "-01") {
+		t.Errorf("traceparent: got %v", traceparent)
+	}
+	if tc, ok := u.TraceContextFromHeader(header); !ok || tc.TraceID != "a3ce929d0e0e4736" {
+		t.Errorf("round trip: got %+v", tc)
+	}
+}
+
+func TestShortHexTraceIDKeptAcrossHops(t *testing.T) {
+	for _, traceID := range []string{"abc123def456", "a3ce929d0e0e4736", testTraceID} {
+		t.Run(traceID, func(t *testing.T) {
+			// gRPC: caller -> hop 1 -> hop 2
+			md := metadata.Pairs("tid", traceID)
+			var spanIDs []string
+			for hop := 1; hop <= 2; hop++ {
+				ctx := u.NewCTXWithGRPCContext(metadata.NewIncomingContext(context.Background(), md))
+				if ctx.TraceID() != traceID {
+					t.Fatalf("hop %d: got trace ID %v", hop, ctx.TraceID())
+				}
+				spanIDs = append(spanIDs, ctx.SpanID())
+				md, _ = metadata.FromOutgoingContext(ctx.FillGRPCContext(context.Background()))
+			}
+			if tc, _ := u.TraceContextFromMD(md); tc.TraceID != traceID || tc.SpanID != spanIDs[1] {
+				t.Errorf("after hop 2: got %+v; want span %v", tc, spanIDs[1])
+			}
+
+			// HTTP: hop 1 -> hop 2
+			header := http.Header{}
+			u.InjectTraceHeaders(u.NewCTXWithTraceID(traceID), header)
+			tc, ok := u.TraceContextFromHeader(header)
+			if !ok || tc.TraceID != traceID {
+				t.Errorf("HTTP: got %+v", tc)
+			}
+		})
+	}
+}
+
+func TestTraceHeadersOfLegacyTraceID(t *testing.T) {
+	header := http.Header{}
+	u.InjectTraceHeaders(u.NewCTXWithTraceID("legacy-trace-id"), header)
+	if got := header.Get("tid"); got != "legacy-trace-id" {
+		t.Errorf("tid: got %v", got)
+	}
+	if header.Get("traceparent") != "" || header.Get("b3") != "" {
+		t.Errorf("expect no W3C or B3 headers for non-hex trace ID, got %v", header)
+	}
+}
+
+func TestTraceIDHeaderOverridesTraceContext(t *testing.T) {
+	tests := []struct {
+		name         string
+		traceparent  string
+		traceIDValue string
+		wantParent   string
+		wantState    string
+		wantSampled  bool
+	}{
+		{"another trace", testTraceID, "other-trace-id", "", "", true},
+		{"same trace", testTraceID, testTraceID, testSpanID, "k=v", false},
+		{"same trace, short form", "0000000000000000a3ce929d0e0e4736", "a3ce929d0e0e4736", testSpanID, "k=v", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.ReleaseMode)
+			r := gin.New()
+			r.Use(u.GinMiddleware())
+			var ctx *u.CTX
+			r.GET("/", func(c *gin.Context) {
+				ctx = u.NewGinHelper(c).CTX()
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("traceparent", "00-"+tt.traceparent+"-"+testSpanID+"-00")
+			req.Header.Set("tracestate", "k=v")
+			req.Header.Set(u.TraceIDHeader, tt.traceIDValue)
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			if ctx.TraceID() != tt.traceIDValue {
+				t.Errorf("trace ID: got %v", ctx.TraceID())
+			}
+			if ctx.ParentSpanID() != tt.wantParent || ctx.TraceState() != tt.wantState || ctx.Sampled() != tt.wantSampled {
+				t.Errorf("got parent %q, tracestate %q, sampled %v", ctx.ParentSpanID(), ctx.TraceState(), ctx.Sampled())
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package u
+
+import (
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// Header keys of trace context. Lower case, as required by GRPC metadata.
+// See https://www.w3.org/TR/trace-context/ and https://github.com/openzipkin/b3-propagation.
+const (
+	TraceParentHeader  = "traceparent"
+	TraceStateHeader   = "tracestate"
+	B3Header           = "b3"
+	B3TraceIDHeader    = "x-b3-traceid"
+	B3SpanIDHeader     = "x-b3-spanid"
+	B3ParentSpanHeader = "x-b3-parentspanid"
+	B3SampledHeader    = "x-b3-sampled"
+	// LegacyTraceIDHeader is the key used before W3C Trace Context is supported.
+	LegacyTraceIDHeader = "tid"
+)
+
+// maxTraceIDLength limits trace ID from TraceIDHeader and LegacyTraceIDHeader, since it is written to logs.
+const maxTraceIDLength = 128
+
+// isLoggableTraceID reports whether traceID is at most maxTraceIDLength printable ASCII characters without spaces.
+// Trace IDs not in W3C or B3 format are checked by it before being written to logs and response headers.
+func isLoggableTraceID(traceID string) bool {
+	if traceID == "" || len(traceID) > maxTraceIDLength {
+		return false
+	}
+	for i := 0; i < len(traceID); i++ {
+		if traceID[i] <= ' ' || traceID[i] >= 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// TraceContext is the trace information received from a caller.
+type TraceContext struct {
+	TraceID string
+	// SpanID is the span of the caller, which is the parent span of the callee.
+	SpanID     string
+	TraceState string
+	// NotSampled is true if the caller explicitly asked not to sample.
+	NotSampled bool
+}
+
+// NewTraceID returns a W3C trace-id, which is 32 lower case hex characters.
+func NewTraceID() string {
+	return ShortUUID(32)
+}
+
+// NewSpanID returns a W3C parent-id, which is 16 lower case hex characters.
+func NewSpanID() string {
+	return ShortUUID(16)
+}
+
+// TraceContextFromHeader extracts trace context from HTTP headers.
+// traceparent takes precedence over b3, then X-B3-*, then legacy tid. Invalid headers are ignored.
+// If the trace ID of traceparent or b3 is legacy tid left-padded with zeros, as sent by InjectTraceHeaders, tid is kept as the trace ID.
+func TraceContextFromHeader(header http.Header) (TraceContext, bool) {
+	return traceContextFrom(header.Get)
+}
+
+// TraceContextFromMD extracts trace context from GRPC metadata. See TraceContextFromHeader.
+func TraceContextFromMD(md metadata.MD) (TraceContext, bool) {
+	return traceContextFrom(func(key string) string {
+		vs := md.Get(key)
+		if len(vs) > 0 {
+			return vs[0]
+		}
+		return ""
+	})
+}
+
+func traceContextFrom(get func(key string) string) (tc TraceContext, ok bool) {
+	if tc, ok = parseTraceParent(get(TraceParentHeader)); ok {
+		tc.TraceState = get(TraceStateHeader)
+		return keepLegacyTraceID(tc, get(LegacyTraceIDHeader)), true
+	}
+	if tc, ok = parseB3(get(B3Header)); ok {
+		return keepLegacyTraceID(tc, get(LegacyTraceIDHeader)), true
+	}
+	if traceID := get(B3TraceIDHeader); isHex(traceID) && (len(traceID) == 16 || len(traceID) == 32) {
+		tc = TraceContext{
+			TraceID:    traceID,
+			NotSampled: get(B3SampledHeader) == "0",
+		}
+		if spanID := get(B3SpanIDHeader); len(spanID) == 16 && isHex(spanID) {
+			tc.SpanID = spanID
+		}
+		return tc, true
+	}
+	if traceID := get(LegacyTraceIDHeader); isLoggableTraceID(traceID) {
+		return TraceContext{TraceID: traceID}, true
+	}
+	return TraceContext{}, false
+}
+
+// keepLegacyTraceID replaces the trace ID of tc with legacy, if the former is the latter as a W3C trace-id.
+// So that a trace started with a short hex ID keeps the ID through services which send it in traceparent and b3.
+func keepLegacyTraceID(tc TraceContext, legacy string) TraceContext {
+	if legacy == tc.TraceID || !isLoggableTraceID(legacy) {
+		return tc
+	}
+	if sameTraceID(legacy, tc.TraceID) {
+		tc.TraceID = legacy
+	}
+	return tc
+}
+
+// sameTraceID reports whether a and b are equal, or equal as W3C trace-ids, e.g. "abc123" and "0000...0abc123".
+func sameTraceID(a, b string) bool {
+	if a == b {
+		return true
+	}
+	x, ok := w3cTraceID(a)
+	y, ok2 := w3cTraceID(b)
+	return ok && ok2 && x == y
+}
+
+// parseTraceParent parses "version-traceid-parentid-flags", e.g. "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func parseTraceParent(v string) (tc TraceContext, ok bool) {
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 {
+		return
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if len(version) != 2 || !isHex(version) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return
+	}
+	if len(traceID) != 32 || !isHex(traceID) || strings.Count(traceID, "0") == 32 {
+		return
+	}
+	if len(spanID) != 16 || !isHex(spanID) || strings.Count(spanID, "0") == 16 {
+		return
+	}
+	if len(flags) != 2 || !isHex(flags) {
+		return
+	}
+	return TraceContext{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		NotSampled: (hexValue(flags[1]) & 1) == 0,
+	}, true
+}
+
+// parseB3 parses single header "traceid-spanid-sampled-parentspanid". Header with sampling state only, such as "0", is ignored.
+func parseB3(v string) (tc TraceContext, ok bool) {
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 2 {
+		return
+	}
+	traceID, spanID := parts[0], parts[1]
+	if (len(traceID) != 16 && len(traceID) != 32) || !isHex(traceID) {
+		return
+	}
+	if len(spanID) != 16 || !isHex(spanID) {
+		return
+	}
+	tc = TraceContext{TraceID: traceID, SpanID: spanID}
+	if len(parts) > 2 {
+		tc.NotSampled = parts[2] == "0"
+	}
+	return tc, true
+}
+
+// w3cTraceID returns traceID as a W3C trace-id. Shorter hex IDs, such as 64-bit B3 IDs or legacy UUID12, are left-padded with zeros.
+func w3cTraceID(traceID string) (string, bool) {
+	traceID = strings.ToLower(traceID)
+	if len(traceID) == 0 || len(traceID) > 32 || !isHex(traceID) {
+		return "", false
+	}
+	return strings.Repeat("0", 32-len(traceID)) + traceID, true
+}
+
+// traceHeaders returns key/value pairs to propagate traceID and spanID.
+// Legacy tid is always included for services not upgraded yet.
+func traceHeaders(traceID, spanID, traceState string, notSampled bool) []string {
+	kv := []string{LegacyTraceIDHeader, traceID}
+	id, ok := w3cTraceID(traceID)
+	if !ok || len(spanID) != 16 || !isHex(spanID) {
+		return kv
+	}
+	flags, sampled := "01", "1"
+	if notSampled {
+		flags, sampled = "00", "0"
+	}
+	kv = append(kv,
+		TraceParentHeader, "00-"+id+"-"+spanID+"-"+flags,
+		B3Header, id+"-"+spanID+"-"+sampled,
+	)
+	if traceState != "" {
+		kv = append(kv, TraceStateHeader, traceState)
+	}
+	return kv
+}
+
+// InjectTraceHeaders sets traceparent, tracestate, b3 and tid of ctx to header of an outgoing HTTP request.
+func InjectTraceHeaders(ctx *CTX, header http.Header) {
+	kv := traceHeaders(ctx.TraceID(), ctx.SpanID(), ctx.TraceState(), ctx.notSampled)
+	for i := 0; i+1 < len(kv); i += 2 {
+		header.Set(kv[i], kv[i+1])
+	}
+}
+
+func isHex(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if hexValue(s[i]) > 15 {
+			return false
+		}
+	}
+	return true
+}
+
+// hexValue returns 0xff if c is not a lower case hex character.
+func hexValue(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	}
+	return 0xff
+}